@@ -0,0 +1,296 @@
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/fileutil"
+)
+
+// metaExt is appended to a recording's file name for its sidecar index.
+const metaExt = ".json"
+
+// nameLayout is the UTC timestamp recordings are named after.
+const nameLayout = "2006-01-02T15-04-05Z"
+
+// TrackEntry is one track that played during a recording.
+type TrackEntry struct {
+	Title     string    `json:"title"`
+	Artist    string    `json:"artist,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	// Offset is where the track starts within the file, in seconds.
+	Offset float64 `json:"offsetSeconds"`
+}
+
+// Recording describes one archived file.
+type Recording struct {
+	Name      string       `json:"name"`
+	Mount     string       `json:"mount"`
+	Slot      string       `json:"slot,omitempty"` // schedule time tag when the file started
+	StartedAt time.Time    `json:"startedAt"`
+	EndedAt   *time.Time   `json:"endedAt,omitempty"`
+	Size      int64        `json:"size"`
+	Active    bool         `json:"active"` // still being written
+	Tracks    []TrackEntry `json:"tracks"`
+}
+
+// Archive manages the recordings in a directory and enforces the retention
+// limits. Each audio file has a JSON sidecar with the same name plus ".json"
+// listing what played in it.
+type Archive struct {
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+
+	mu      sync.Mutex
+	current *File
+}
+
+// New creates an Archive in dir. Recordings older than maxAge are deleted,
+// as are the oldest recordings while the archive exceeds maxSize bytes; a
+// zero limit disables that check.
+func New(dir string, maxAge time.Duration, maxSize int64) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory %q: %w", dir, err)
+	}
+	return &Archive{dir: dir, maxAge: maxAge, maxSize: maxSize}, nil
+}
+
+// Create starts a new recording file. ext includes the leading dot.
+func (a *Archive) Create(start time.Time, ext, mount, slot string) (*File, error) {
+	start = start.UTC()
+	name := start.Format(nameLayout) + ext
+	f, err := os.OpenFile(filepath.Join(a.dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording %q: %w", name, err)
+	}
+	rf := &File{
+		archive: a,
+		f:       f,
+		rec: Recording{
+			Name:      name,
+			Mount:     mount,
+			Slot:      slot,
+			StartedAt: start,
+			Tracks:    []TrackEntry{},
+		},
+	}
+	if err := rf.saveMeta(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.current = rf
+	a.mu.Unlock()
+	return rf, nil
+}
+
+// List returns every recording, newest first.
+func (a *Archive) List() ([]Recording, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	a.mu.Lock()
+	var activeName string
+	if a.current != nil {
+		activeName = a.current.rec.Name
+	}
+	a.mu.Unlock()
+
+	var recs []Recording
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), metaExt) {
+			continue
+		}
+		rec, err := a.load(strings.TrimSuffix(e.Name(), metaExt))
+		if err != nil {
+			slog.Warn("Skipping unreadable recording", "file", e.Name(), "error", err)
+			continue
+		}
+		rec.Active = rec.Name == activeName
+		recs = append(recs, rec)
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].StartedAt.After(recs[j].StartedAt) })
+	return recs, nil
+}
+
+// Get returns the recording with the given name.
+func (a *Archive) Get(name string) (Recording, error) {
+	if !validName(name) {
+		return Recording{}, fmt.Errorf("recording %q not found", name)
+	}
+	rec, err := a.load(name)
+	if err != nil {
+		return Recording{}, fmt.Errorf("recording %q not found", name)
+	}
+	a.mu.Lock()
+	rec.Active = a.current != nil && a.current.rec.Name == name
+	a.mu.Unlock()
+	return rec, nil
+}
+
+// Path returns the audio file of the named recording.
+func (a *Archive) Path(name string) (string, error) {
+	if _, err := a.Get(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(a.dir, name), nil
+}
+
+// Delete removes a finished recording and its sidecar.
+func (a *Archive) Delete(name string) error {
+	rec, err := a.Get(name)
+	if err != nil {
+		return err
+	}
+	if rec.Active {
+		return fmt.Errorf("recording %q is still being written", name)
+	}
+	return a.remove(name)
+}
+
+func (a *Archive) remove(name string) error {
+	if err := os.Remove(filepath.Join(a.dir, name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording %q: %w", name, err)
+	}
+	if err := os.Remove(filepath.Join(a.dir, name+metaExt)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete recording index %q: %w", name, err)
+	}
+	return nil
+}
+
+// Prune deletes finished recordings beyond the age and size limits, oldest
+// first. The recording in progress is never deleted.
+func (a *Archive) Prune(now time.Time) {
+	recs, err := a.List()
+	if err != nil {
+		slog.Error("Failed to prune archive", "error", err)
+		return
+	}
+
+	var total int64
+	for _, r := range recs {
+		total += r.Size
+	}
+	// Oldest first.
+	for i := len(recs) - 1; i >= 0; i-- {
+		r := recs[i]
+		if r.Active {
+			continue
+		}
+		tooOld := a.maxAge > 0 && now.Sub(r.StartedAt) > a.maxAge
+		tooBig := a.maxSize > 0 && total > a.maxSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		if err := a.remove(r.Name); err != nil {
+			slog.Error("Failed to delete expired recording", "name", r.Name, "error", err)
+			continue
+		}
+		total -= r.Size
+		slog.Info("Deleted expired recording", "name", r.Name, "too_old", tooOld, "over_size_limit", tooBig)
+	}
+}
+
+// load reads a recording's sidecar and the current size of its audio file.
+func (a *Archive) load(name string) (Recording, error) {
+	raw, err := os.ReadFile(filepath.Join(a.dir, name+metaExt))
+	if err != nil {
+		return Recording{}, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return Recording{}, err
+	}
+	if info, err := os.Stat(filepath.Join(a.dir, name)); err == nil {
+		rec.Size = info.Size()
+	}
+	return rec, nil
+}
+
+// validName rejects anything that is not a plain file name in the archive.
+func validName(name string) bool {
+	return name != "" && name == filepath.Base(name) && !strings.HasPrefix(name, ".") && !strings.HasSuffix(name, metaExt)
+}
+
+// ---------------------------------------------------------------------------
+// File is a recording in progress.
+// ---------------------------------------------------------------------------
+
+type File struct {
+	archive *Archive
+	f       *os.File
+
+	mu  sync.Mutex
+	rec Recording
+}
+
+// Name returns the recording's file name.
+func (rf *File) Name() string {
+	return rf.rec.Name
+}
+
+// Write appends encoded audio.
+func (rf *File) Write(p []byte) (int, error) {
+	return rf.f.Write(p)
+}
+
+// AddTrack records that a track started at offset into the file.
+func (rf *File) AddTrack(title, artist string, at time.Time, offset time.Duration) {
+	rf.mu.Lock()
+	rf.rec.Tracks = append(rf.rec.Tracks, TrackEntry{
+		Title:     title,
+		Artist:    artist,
+		StartedAt: at.UTC(),
+		Offset:    offset.Seconds(),
+	})
+	rf.mu.Unlock()
+	if err := rf.saveMeta(); err != nil {
+		slog.Error("Failed to update recording index", "name", rf.rec.Name, "error", err)
+	}
+}
+
+// Close finishes the recording.
+func (rf *File) Close() error {
+	err := rf.f.Close()
+
+	ended := time.Now().UTC()
+	rf.mu.Lock()
+	rf.rec.EndedAt = &ended
+	rf.mu.Unlock()
+	if merr := rf.saveMeta(); merr != nil && err == nil {
+		err = merr
+	}
+
+	a := rf.archive
+	a.mu.Lock()
+	if a.current == rf {
+		a.current = nil
+	}
+	a.mu.Unlock()
+	return err
+}
+
+// saveMeta writes the sidecar atomically (write to temp file, then rename).
+func (rf *File) saveMeta() error {
+	rf.mu.Lock()
+	jsonBytes, err := json.MarshalIndent(rf.rec, "", "  ")
+	rf.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording index: %w", err)
+	}
+
+	dir := rf.archive.dir
+	path := filepath.Join(dir, rf.rec.Name+metaExt)
+	return fileutil.WriteAtomic(path, jsonBytes)
+}
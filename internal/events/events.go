@@ -0,0 +1,186 @@
+// Package events fans out station events, such as track changes, to
+// real-time subscribers like the dashboard and stream overlays.
+package events
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// Type identifies the kind of an event.
+type Type string
+
+const (
+	// TypeState carries the full station status. It is sent to every new
+	// subscriber on connect and cannot be filtered out.
+	TypeState            Type = "state"
+	TypeTrackStarted     Type = "track_started"
+	TypeListeners        Type = "listeners_changed"
+	TypeTagSwitched      Type = "tag_switched"
+	TypePlaylistModified Type = "playlist_modified"
+	TypeSkipRequested    Type = "skip_requested"
+)
+
+// Types lists the event types subscribers can filter on.
+var Types = []Type{
+	TypeTrackStarted,
+	TypeListeners,
+	TypeTagSwitched,
+	TypePlaylistModified,
+	TypeSkipRequested,
+}
+
+// ParseTypes parses a comma-separated list of event types. An empty list
+// selects every type.
+func ParseTypes(s string) (map[Type]bool, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	filter := make(map[Type]bool)
+	for _, part := range strings.Split(s, ",") {
+		t := Type(strings.TrimSpace(part))
+		if t == "" {
+			continue
+		}
+		valid := false
+		for _, known := range Types {
+			if t == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown event type %q", t)
+		}
+		filter[t] = true
+	}
+	return filter, nil
+}
+
+// TrackStarted is the payload of TypeTrackStarted. Playlist is nil for
+// tracks that do not come from a playlist, such as a live DJ's metadata.
+// StartedAt is when listeners start hearing the track, after their buffer
+// latency; Offset is where in the track playback began, non-zero when it
+// resumed after a restart.
+type TrackStarted struct {
+	Track     *playlist.Track
+	Playlist  *playlist.Playlist
+	Live      bool
+	StartedAt time.Time
+	Offset    time.Duration
+}
+
+// ListenersChanged is the payload of TypeListeners.
+type ListenersChanged struct {
+	Total          int
+	Mount          string
+	MountListeners int
+}
+
+// TagSwitched is the payload of TypeTagSwitched.
+type TagSwitched struct {
+	Previous  playlist.TimeTag
+	Current   playlist.TimeTag
+	Day       time.Weekday       // whose schedule Current plays
+	Override  string             // name of the schedule override in force, if any
+	HardStart bool               // the track on air was cut for the new slot
+	Playlist  *playlist.Playlist // nil if the new tag has nothing to play
+}
+
+// PlaylistModified is the payload of TypePlaylistModified. PlaylistID is
+// zero for changes that are not about a single playlist.
+type PlaylistModified struct {
+	Action     string // created, updated, deleted, tracks_changed, ...
+	PlaylistID int64
+}
+
+// SkipRequested is the payload of TypeSkipRequested.
+type SkipRequested struct {
+	Direction string // next or prev
+}
+
+// Event is one occurrence of a Type. Data holds the matching payload struct.
+type Event struct {
+	Type Type
+	Time time.Time
+	Data any
+}
+
+// Subscription receives events from a Bus.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter map[Type]bool // nil receives everything
+}
+
+func (s *Subscription) wants(t Type) bool {
+	return s.filter == nil || s.filter[t]
+}
+
+// subscriptionBuffer is how many events may queue for a subscriber before it
+// is dropped as too slow.
+const subscriptionBuffer = 64
+
+// Bus delivers published events to every interested subscriber. A nil *Bus
+// is valid and discards everything, so publishers need not check for it.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber for the types in filter, or for every
+// type if filter is nil. The caller must call Unsubscribe when done.
+func (b *Bus) Subscribe(filter map[Type]bool) *Subscription {
+	ch := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe removes sub and closes its channel. It is safe to call more
+// than once.
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish sends an event to every subscriber that wants it. It never blocks:
+// a subscriber whose buffer is full is dropped, and its closed channel tells
+// the client to reconnect and pick up fresh state.
+func (b *Bus) Publish(t Type, data any) {
+	if b == nil {
+		return
+	}
+	ev := Event{Type: t, Time: time.Now().UTC(), Data: data}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !sub.wants(t) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			slog.Warn("Dropping slow event subscriber", "event", t)
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
@@ -9,85 +9,72 @@ import (
 	"os/exec"
 )
 
-type Encoder struct {
+// Encoder decodes audio to PCM and encodes PCM into stream formats. The
+// broadcaster and services depend on this interface rather than on the
+// ffmpeg binary so they can run against FakeEncoder.
+type Encoder interface {
+	// Bitrate returns the default bitrate used when a format does not set
+	// one.
+	Bitrate() string
+	SampleRateHz() int
+	ChannelCount() int
+	// FrameBytes is the size of one PCM sample frame.
+	FrameBytes() int
+	// BytesPerSecond is the PCM data rate of Decode and LiveEncoder.
+	BytesPerSecond() int
+
+	// Decode writes inputFile as PCM to output.
+	Decode(ctx context.Context, inputFile string, opts DecodeOptions, output io.Writer) error
+	// DecodeStream writes an encoded stream read from input as PCM to
+	// output. format is a demuxer hint and may be empty.
+	DecodeStream(ctx context.Context, format string, input io.Reader, output io.Writer) error
+	// StartLive starts a long-lived encoder whose output is copied to
+	// output.
+	StartLive(ctx context.Context, format OutputFormat, output io.Writer) (LiveEncoder, error)
+
+	AnalyzeLoudness(ctx context.Context, inputFile string) (Loudness, error)
+	// Probe reads the duration and stream properties of inputFile.
+	Probe(ctx context.Context, inputFile string) (AudioInfo, error)
+	ConvertToOGG(ctx context.Context, inputFile, outputFile string) error
+}
+
+// LiveEncoder turns PCM written to it into one continuous encoded stream.
+type LiveEncoder interface {
+	io.Writer
+	// Done is closed when the encoder has stopped.
+	Done() <-chan struct{}
+	// Err returns why the encoder stopped, or nil while it is running or if
+	// it was stopped through its context.
+	Err() error
+	// Close ends the input and waits for the encoder to flush and stop.
+	Close() error
+}
+
+// CLIEncoder implements Encoder by running the ffmpeg binary.
+type CLIEncoder struct {
 	bitrate    string
 	sampleRate string
 	channels   string
 }
 
-func NewEncoder(bitrate, sampleRate, channels string) *Encoder {
-	return &Encoder{
+func NewEncoder(bitrate, sampleRate, channels string) *CLIEncoder {
+	return &CLIEncoder{
 		bitrate:    bitrate,
 		sampleRate: sampleRate,
 		channels:   channels,
 	}
 }
 
-func (e *Encoder) Stream(ctx context.Context, inputFile string, output io.Writer) error {
-	args := []string{
-		"-re",           // Real-time processing
-		"-i", inputFile, // Input file
-		"-f", "mp3", // Output format
-		"-b:a", e.bitrate, // Audio bitrate
-		"-ac", e.channels, // Audio channels (stereo)
-		"-ar", e.sampleRate, // Sample rate
-		"-vn",    // No video
-		"pipe:1", // Output to stdout
-	}
-
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-
-	// Start FFmpeg
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
-	}
-
-	// Log FFmpeg errors in background
-	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, err := stderr.Read(buf)
-			if err != nil {
-				return
-			}
-			if n > 0 {
-				slog.Debug("ffmpeg", "output", string(buf[:n]))
-			}
-		}
-	}()
-
-	// Copy output to writer
-	_, copyErr := io.Copy(output, stdout)
-
-	// Wait for command to finish
-	waitErr := cmd.Wait()
-
-	if copyErr != nil && ctx.Err() == nil {
-		return fmt.Errorf("stream copy error: %w", copyErr)
-	}
-
-	if waitErr != nil && ctx.Err() == nil {
-		return fmt.Errorf("ffmpeg process error: %w", waitErr)
-	}
-
-	return nil
+// Bitrate returns the default bitrate used when a format does not set one.
+func (e *CLIEncoder) Bitrate() string {
+	return e.bitrate
 }
 
 // ConvertToOGG converts an audio file to OGG Vorbis format. The output file
 // is written to outputFile. The conversion uses the encoder's configured
 // bitrate, sample rate, and channel count. Metadata from the source file is
 // preserved automatically by ffmpeg.
-func (e *Encoder) ConvertToOGG(ctx context.Context, inputFile, outputFile string) error {
+func (e *CLIEncoder) ConvertToOGG(ctx context.Context, inputFile, outputFile string) error {
 	args := []string{
 		"-y",            // Overwrite output without asking
 		"-i", inputFile, // Input file
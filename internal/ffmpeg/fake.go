@@ -0,0 +1,329 @@
+package ffmpeg
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeEncoder is an in-process Encoder for tests. It never runs ffmpeg:
+// Decode produces a deterministic PCM ramp of a configurable length per
+// file, and StartLive turns PCM into well-formed but silent MP3 or ADTS
+// frames (opaque fixed-size packets for Opus) at exactly the rate a real
+// encoder would, so frame splitting, bursts and timing behave as in
+// production.
+type FakeEncoder struct {
+	bitrate    string
+	sampleRate int
+	channels   int
+
+	mu              sync.Mutex
+	defaultDuration float64 // seconds
+	durations       map[string]float64
+	errors          map[string]error
+	loudness        map[string]Loudness
+	decoded         []string
+}
+
+// NewFakeEncoder creates a FakeEncoder producing PCM at sampleRate with the
+// given channel count. Files decode to three seconds of audio unless
+// SetDuration says otherwise.
+func NewFakeEncoder(bitrate string, sampleRate, channels int) *FakeEncoder {
+	return &FakeEncoder{
+		bitrate:         bitrate,
+		sampleRate:      sampleRate,
+		channels:        channels,
+		defaultDuration: 3,
+		durations:       make(map[string]float64),
+		errors:          make(map[string]error),
+		loudness:        make(map[string]Loudness),
+	}
+}
+
+// SetDuration sets how many seconds of audio Decode produces for file.
+func (f *FakeEncoder) SetDuration(file string, seconds float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.durations[file] = seconds
+}
+
+// SetDefaultDuration sets the length of files without their own duration.
+func (f *FakeEncoder) SetDefaultDuration(seconds float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.defaultDuration = seconds
+}
+
+// SetError makes Decode, AnalyzeLoudness and Probe of file fail with err.
+func (f *FakeEncoder) SetError(file string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[file] = err
+}
+
+// SetLoudness sets what AnalyzeLoudness reports for file.
+func (f *FakeEncoder) SetLoudness(file string, l Loudness) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loudness[file] = l
+}
+
+// Decoded returns the files passed to Decode so far, in order.
+func (f *FakeEncoder) Decoded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.decoded...)
+}
+
+func (f *FakeEncoder) Bitrate() string     { return f.bitrate }
+func (f *FakeEncoder) SampleRateHz() int   { return f.sampleRate }
+func (f *FakeEncoder) ChannelCount() int   { return f.channels }
+func (f *FakeEncoder) FrameBytes() int     { return f.channels * bytesPerSample }
+func (f *FakeEncoder) BytesPerSecond() int { return f.sampleRate * f.FrameBytes() }
+
+// Decode writes the file's configured duration of PCM to output in 20 ms
+// blocks as fast as output accepts it. Sample n of every file has the value
+// n mod 32768 on all channels, so output is reproducible; opts.Start skips
+// samples and opts.GainDB is ignored.
+func (f *FakeEncoder) Decode(ctx context.Context, inputFile string, opts DecodeOptions, output io.Writer) error {
+	f.mu.Lock()
+	f.decoded = append(f.decoded, inputFile)
+	err := f.errors[inputFile]
+	seconds, ok := f.durations[inputFile]
+	if !ok {
+		seconds = f.defaultDuration
+	}
+	f.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	total := int(seconds * float64(f.sampleRate))
+	block := f.sampleRate / 50
+	buf := make([]byte, block*f.FrameBytes())
+	for n := int(opts.Start.Seconds() * float64(f.sampleRate)); n < total; {
+		if ctx.Err() != nil {
+			return nil
+		}
+		frames := min(block, total-n)
+		for i := 0; i < frames; i++ {
+			v := uint16((n + i) % 32768)
+			for ch := 0; ch < f.channels; ch++ {
+				binary.LittleEndian.PutUint16(buf[(i*f.channels+ch)*bytesPerSample:], v)
+			}
+		}
+		if _, err := output.Write(buf[:frames*f.FrameBytes()]); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("decode copy error: %w", err)
+		}
+		n += frames
+	}
+	return nil
+}
+
+// DecodeStream treats input as PCM and copies it to output.
+func (f *FakeEncoder) DecodeStream(ctx context.Context, format string, input io.Reader, output io.Writer) error {
+	buf := make([]byte, f.BytesPerSecond()/50)
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		n, err := input.Read(buf)
+		if n > 0 {
+			if _, werr := output.Write(buf[:n]); werr != nil {
+				return fmt.Errorf("stream decode copy error: %w", werr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// AnalyzeLoudness returns the loudness set with SetLoudness, or -16 LUFS
+// with a -1 dBTP peak.
+func (f *FakeEncoder) AnalyzeLoudness(ctx context.Context, inputFile string) (Loudness, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errors[inputFile]; err != nil {
+		return Loudness{}, err
+	}
+	if l, ok := f.loudness[inputFile]; ok {
+		return l, nil
+	}
+	return Loudness{Integrated: -16, TruePeak: -1}, nil
+}
+
+// Probe reports the file's configured duration at the encoder's sample
+// rate and channel count.
+func (f *FakeEncoder) Probe(ctx context.Context, inputFile string) (AudioInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errors[inputFile]; err != nil {
+		return AudioInfo{}, err
+	}
+	seconds, ok := f.durations[inputFile]
+	if !ok {
+		seconds = f.defaultDuration
+	}
+	bitrate, _ := strconv.Atoi(strings.TrimSuffix(strings.ToLower(f.bitrate), "k"))
+	return AudioInfo{
+		Duration:   time.Duration(seconds * float64(time.Second)),
+		Codec:      "pcm_s16le",
+		Bitrate:    bitrate * 1000,
+		SampleRate: f.sampleRate,
+		Channels:   f.channels,
+	}, nil
+}
+
+// ConvertToOGG copies inputFile to outputFile unchanged.
+func (f *FakeEncoder) ConvertToOGG(ctx context.Context, inputFile, outputFile string) error {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		return fmt.Errorf("ffmpeg OGG conversion failed: %w", err)
+	}
+	return os.WriteFile(outputFile, data, 0o644)
+}
+
+// StartLive returns a LiveEncoder that writes one encoded frame to output
+// for every frame's worth of PCM written to it.
+func (f *FakeEncoder) StartLive(ctx context.Context, format OutputFormat, output io.Writer) (LiveEncoder, error) {
+	if format.Bitrate == "" {
+		format.Bitrate = f.bitrate
+	}
+	frame, samples := fakeFrame(format, f.sampleRate, f.channels)
+	fl := &fakeLive{
+		output:     output,
+		frame:      frame,
+		frameBytes: samples * f.FrameBytes(),
+		done:       make(chan struct{}),
+	}
+	context.AfterFunc(ctx, func() { fl.stop(nil) })
+	return fl, nil
+}
+
+// fakeFrame builds the frame a FakeEncoder live stream repeats, and the
+// number of PCM samples it stands for.
+func fakeFrame(format OutputFormat, sampleRate, channels int) ([]byte, int) {
+	kbps, _ := strconv.Atoi(strings.TrimSuffix(strings.ToLower(format.Bitrate), "k"))
+	if kbps <= 0 {
+		kbps = 128
+	}
+
+	switch format.Codec {
+	case CodecMP3:
+		srIdx := map[int]byte{44100: 0, 48000: 1, 32000: 2}
+		rates := []int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+		if idx, ok := srIdx[sampleRate]; ok {
+			brIdx := 9 // 128 kbps
+			for i, r := range rates {
+				if r == kbps {
+					brIdx = i + 1
+				}
+			}
+			size := 144 * rates[brIdx-1] * 1000 / sampleRate
+			frame := make([]byte, size)
+			frame[0], frame[1] = 0xFF, 0xFB // MPEG-1 Layer III, no CRC
+			frame[2] = byte(brIdx)<<4 | idx<<2
+			if channels == 1 {
+				frame[3] = 0xC0
+			}
+			return frame, 1152
+		}
+	case CodecAAC:
+		rates := []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+		for i, r := range rates {
+			if r != sampleRate {
+				continue
+			}
+			size := 7 + kbps*1000*1024/sampleRate/8
+			frame := make([]byte, size)
+			frame[0], frame[1] = 0xFF, 0xF1 // MPEG-4, no CRC
+			frame[2] = 1<<6 | byte(i)<<2 | byte(channels>>2)&0x1
+			frame[3] = byte(channels&0x3)<<6 | byte(size>>11)&0x3
+			frame[4] = byte(size >> 3)
+			frame[5] = byte(size&0x7)<<5 | 0x1F
+			frame[6] = 0xFC
+			return frame, 1024
+		}
+	}
+
+	// Opus, or a sample rate the container cannot express: opaque 20 ms
+	// packets.
+	samples := sampleRate / 50
+	return make([]byte, kbps*1000/50/8), samples
+}
+
+// fakeLive is the LiveEncoder returned by FakeEncoder.StartLive.
+type fakeLive struct {
+	output     io.Writer
+	frame      []byte
+	frameBytes int
+
+	mu      sync.Mutex
+	pending int
+	done    chan struct{}
+	stopped bool
+	err     error
+}
+
+func (fl *fakeLive) Write(p []byte) (int, error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	if fl.stopped {
+		if fl.err == nil {
+			return 0, io.ErrClosedPipe
+		}
+		return 0, fl.err
+	}
+	fl.pending += len(p)
+	for fl.pending >= fl.frameBytes {
+		fl.pending -= fl.frameBytes
+		if _, err := fl.output.Write(fl.frame); err != nil {
+			fl.stopUnsafe(fmt.Errorf("live encoder copy error: %w", err))
+			return 0, fl.err
+		}
+	}
+	return len(p), nil
+}
+
+func (fl *fakeLive) Done() <-chan struct{} {
+	return fl.done
+}
+
+func (fl *fakeLive) Err() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	return fl.err
+}
+
+func (fl *fakeLive) Close() error {
+	fl.stop(io.EOF)
+	return nil
+}
+
+func (fl *fakeLive) stop(err error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.stopUnsafe(err)
+}
+
+func (fl *fakeLive) stopUnsafe(err error) {
+	if fl.stopped {
+		return
+	}
+	fl.stopped = true
+	fl.err = err
+	close(fl.done)
+}
@@ -0,0 +1,365 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PCM sample format shared by Decode and LiveEncoder: signed 16-bit little
+// endian, interleaved channels.
+const (
+	pcmFormat      = "s16le"
+	bytesPerSample = 2
+)
+
+// SampleRateHz returns the configured sample rate as an integer, falling back
+// to 44100 when the configured value is not numeric.
+func (e *CLIEncoder) SampleRateHz() int {
+	if n, err := strconv.Atoi(e.sampleRate); err == nil && n > 0 {
+		return n
+	}
+	return 44100
+}
+
+// ChannelCount returns the configured channel count as an integer, falling
+// back to stereo when the configured value is not numeric.
+func (e *CLIEncoder) ChannelCount() int {
+	if n, err := strconv.Atoi(e.channels); err == nil && n > 0 {
+		return n
+	}
+	return 2
+}
+
+// FrameBytes is the size in bytes of one PCM sample frame (one sample for
+// every channel).
+func (e *CLIEncoder) FrameBytes() int {
+	return e.ChannelCount() * bytesPerSample
+}
+
+// BytesPerSecond is the PCM data rate produced by Decode and expected by
+// LiveEncoder.
+func (e *CLIEncoder) BytesPerSecond() int {
+	return e.SampleRateHz() * e.FrameBytes()
+}
+
+// DecodeOptions adjusts how a file is decoded.
+type DecodeOptions struct {
+	// GainDB is applied as a volume change, e.g. for loudness normalisation.
+	GainDB float64
+	// Start skips this far into the file, e.g. to resume after a restart.
+	Start time.Duration
+}
+
+// Decode decodes inputFile to raw PCM at the encoder's sample rate and
+// channel count and writes it to output as fast as ffmpeg produces it.
+// Pacing to real time is the caller's job. It returns when the file is fully
+// decoded or ctx is cancelled.
+func (e *CLIEncoder) Decode(ctx context.Context, inputFile string, opts DecodeOptions, output io.Writer) error {
+	args := []string{"-nostdin"}
+	if opts.Start > 0 {
+		// Before -i so ffmpeg seeks the input instead of decoding up to it.
+		args = append(args, "-ss", strconv.FormatFloat(opts.Start.Seconds(), 'f', 3, 64))
+	}
+	args = append(args,
+		"-i", inputFile,
+		"-vn",
+	)
+	if opts.GainDB != 0 {
+		args = append(args, "-af", "volume="+strconv.FormatFloat(opts.GainDB, 'f', 2, 64)+"dB")
+	}
+	args = append(args,
+		"-f", pcmFormat,
+		"-ac", e.channels,
+		"-ar", e.sampleRate,
+		"pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	go logStderr(stderr)
+
+	_, copyErr := io.Copy(output, stdout)
+	if copyErr != nil {
+		// Stop ffmpeg so Wait does not block on a full stdout pipe.
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	if copyErr != nil && ctx.Err() == nil {
+		return fmt.Errorf("decode copy error: %w", copyErr)
+	}
+
+	if waitErr != nil && ctx.Err() == nil {
+		return fmt.Errorf("ffmpeg decode error: %w", waitErr)
+	}
+
+	return nil
+}
+
+// DecodeStream decodes an encoded audio stream read from input (e.g. a live
+// DJ connection) to raw PCM in the same format as Decode. format is an
+// ffmpeg demuxer name such as "mp3" or "ogg"; empty lets ffmpeg probe. It
+// returns when input ends, ffmpeg fails, or ctx is cancelled.
+func (e *CLIEncoder) DecodeStream(ctx context.Context, format string, input io.Reader, output io.Writer) error {
+	args := []string{"-fflags", "nobuffer"}
+	if format != "" {
+		args = append(args, "-f", format)
+	}
+	args = append(args,
+		"-i", "pipe:0",
+		"-vn",
+		"-f", pcmFormat,
+		"-ac", e.channels,
+		"-ar", e.sampleRate,
+		"pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = input
+	// The stdin copier may be blocked reading a network connection; do not
+	// let it hold up Wait once ffmpeg has exited.
+	cmd.WaitDelay = time.Second
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	go logStderr(stderr)
+
+	_, copyErr := io.Copy(output, stdout)
+	if copyErr != nil {
+		_ = cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+
+	if copyErr != nil && ctx.Err() == nil {
+		return fmt.Errorf("stream decode copy error: %w", copyErr)
+	}
+	if waitErr != nil && ctx.Err() == nil && !errors.Is(waitErr, exec.ErrWaitDelay) {
+		return fmt.Errorf("ffmpeg stream decode error: %w", waitErr)
+	}
+	return nil
+}
+
+// Codec identifies an output codec/container combination for a live
+// encoder.
+type Codec string
+
+const (
+	CodecMP3  Codec = "mp3"  // MPEG-1 Layer III, raw MP3 stream
+	CodecOpus Codec = "opus" // Opus in an Ogg container
+	CodecAAC  Codec = "aac"  // AAC-LC in ADTS framing
+)
+
+// ParseCodec validates a codec name such as "mp3", "opus" or "aac".
+func ParseCodec(name string) (Codec, error) {
+	switch c := Codec(strings.ToLower(strings.TrimSpace(name))); c {
+	case CodecMP3, CodecOpus, CodecAAC:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unsupported codec %q: must be one of mp3, opus, aac", name)
+	}
+}
+
+// ContentType returns the MIME type served to clients for this codec.
+func (c Codec) ContentType() string {
+	switch c {
+	case CodecOpus:
+		return "audio/ogg"
+	case CodecAAC:
+		return "audio/aac"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// Extension returns the file extension, including the dot, for a raw
+// stream in this codec.
+func (c Codec) Extension() string {
+	switch c {
+	case CodecOpus:
+		return ".opus"
+	case CodecAAC:
+		return ".aac"
+	default:
+		return ".mp3"
+	}
+}
+
+// OutputFormat describes what a LiveEncoder produces.
+type OutputFormat struct {
+	Codec   Codec
+	Bitrate string // ffmpeg bitrate syntax, e.g. "128k"
+}
+
+// outputArgs returns the ffmpeg codec/container arguments for the format.
+func (f OutputFormat) outputArgs(sampleRate, channels string) []string {
+	switch f.Codec {
+	case CodecOpus:
+		// libopus only accepts a handful of rates; 48 kHz is the native one.
+		return []string{"-c:a", "libopus", "-b:a", f.Bitrate, "-ac", channels, "-ar", "48000", "-f", "ogg"}
+	case CodecAAC:
+		return []string{"-c:a", "aac", "-b:a", f.Bitrate, "-ac", channels, "-ar", sampleRate, "-f", "adts"}
+	default:
+		return []string{"-c:a", "libmp3lame", "-b:a", f.Bitrate, "-ac", channels, "-ar", sampleRate, "-f", "mp3"}
+	}
+}
+
+// liveProcess is a long-lived ffmpeg process that reads PCM on stdin and
+// writes encoded audio to an io.Writer. Feeding it the PCM of consecutive
+// tracks yields one continuous stream with no encoder reset between tracks.
+type liveProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// StartLive launches a LiveEncoder producing the given format, whose encoded
+// output is copied to output. The process stops when ctx is cancelled or
+// Close is called.
+func (e *CLIEncoder) StartLive(ctx context.Context, format OutputFormat, output io.Writer) (LiveEncoder, error) {
+	if format.Bitrate == "" {
+		format.Bitrate = e.bitrate
+	}
+	args := []string{
+		"-f", pcmFormat, // Raw PCM input
+		"-ac", e.channels,
+		"-ar", e.sampleRate,
+		"-i", "pipe:0",
+		"-vn",
+	}
+	args = append(args, format.outputArgs(e.sampleRate, e.channels)...)
+	args = append(args,
+		"-flush_packets", "1", // Hand every packet to the broadcaster immediately
+		"pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	go logStderr(stderr)
+
+	le := &liveProcess{
+		cmd:   cmd,
+		stdin: stdin,
+		done:  make(chan struct{}),
+	}
+
+	go func() {
+		defer close(le.done)
+		_, copyErr := io.Copy(output, stdout)
+		waitErr := cmd.Wait()
+		le.mu.Lock()
+		defer le.mu.Unlock()
+		switch {
+		case ctx.Err() != nil:
+		case copyErr != nil:
+			le.err = fmt.Errorf("live encoder copy error: %w", copyErr)
+		case waitErr != nil:
+			le.err = fmt.Errorf("live encoder process error: %w", waitErr)
+		default:
+			le.err = io.EOF
+		}
+	}()
+
+	return le, nil
+}
+
+// Write feeds PCM to the encoder. It fails once the ffmpeg process has
+// exited.
+func (le *liveProcess) Write(p []byte) (int, error) {
+	select {
+	case <-le.done:
+		return 0, le.Err()
+	default:
+	}
+	return le.stdin.Write(p)
+}
+
+// Done is closed when the ffmpeg process has exited.
+func (le *liveProcess) Done() <-chan struct{} {
+	return le.done
+}
+
+// Err returns why the process exited, or nil while it is still running or if
+// it was stopped through its context.
+func (le *liveProcess) Err() error {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	return le.err
+}
+
+// Close ends the PCM input and waits for ffmpeg to flush and exit.
+func (le *liveProcess) Close() error {
+	err := le.stdin.Close()
+	<-le.done
+	return err
+}
+
+// logStderr forwards ffmpeg's diagnostic output to the debug log until the
+// pipe is closed.
+func logStderr(stderr io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := stderr.Read(buf)
+		if n > 0 {
+			slog.Debug("ffmpeg", "output", string(buf[:n]))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
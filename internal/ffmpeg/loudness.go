@@ -0,0 +1,66 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// Loudness is the EBU R128 measurement of one audio file.
+type Loudness struct {
+	Integrated float64 // integrated loudness, LUFS
+	TruePeak   float64 // maximum true peak, dBTP
+}
+
+// AnalyzeLoudness runs ffmpeg's loudnorm filter in analysis mode over
+// inputFile and returns its integrated loudness and true peak. Files that
+// are entirely silent have no meaningful loudness and return an error.
+func (e *CLIEncoder) AnalyzeLoudness(ctx context.Context, inputFile string) (Loudness, error) {
+	args := []string{
+		"-nostdin",
+		"-hide_banner",
+		"-i", inputFile,
+		"-vn",
+		"-af", "loudnorm=print_format=json",
+		"-f", "null",
+		"-",
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var stderrBuf bytes.Buffer
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, fmt.Errorf("ffmpeg loudness analysis failed: %w", err)
+	}
+
+	// loudnorm prints its JSON report as the last block on stderr.
+	out := stderrBuf.Bytes()
+	start := bytes.LastIndexByte(out, '{')
+	end := bytes.LastIndexByte(out, '}')
+	if start < 0 || end < start {
+		return Loudness{}, fmt.Errorf("loudness report not found in ffmpeg output")
+	}
+
+	var report struct {
+		InputI  string `json:"input_i"`
+		InputTP string `json:"input_tp"`
+	}
+	if err := json.Unmarshal(out[start:end+1], &report); err != nil {
+		return Loudness{}, fmt.Errorf("failed to parse loudness report: %w", err)
+	}
+
+	integrated, err := strconv.ParseFloat(report.InputI, 64)
+	if err != nil || integrated < -70 {
+		return Loudness{}, fmt.Errorf("no measurable loudness (input_i=%q)", report.InputI)
+	}
+	truePeak, err := strconv.ParseFloat(report.InputTP, 64)
+	if err != nil {
+		return Loudness{}, fmt.Errorf("invalid true peak %q", report.InputTP)
+	}
+	return Loudness{Integrated: integrated, TruePeak: truePeak}, nil
+}
@@ -0,0 +1,103 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds a single ffprobe run so one pathological file cannot
+// stall a library scan.
+const probeTimeout = 30 * time.Second
+
+// AudioInfo describes the first audio stream of a file.
+type AudioInfo struct {
+	Duration   time.Duration
+	Codec      string // ffmpeg codec name, e.g. mp3, flac, vorbis
+	Bitrate    int    // bits per second; 0 if unknown (e.g. lossless)
+	SampleRate int    // Hz
+	Channels   int
+}
+
+// Probe runs ffprobe on inputFile and returns the properties of its first
+// audio stream. Files ffprobe cannot read, or that have no audio stream,
+// return an error.
+func (e *CLIEncoder) Probe(ctx context.Context, inputFile string) (AudioInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	args := []string{
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels,bit_rate,duration:format=duration,bit_rate",
+		"-of", "json",
+		inputFile,
+	}
+
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderrBuf.String()); msg != "" {
+			return AudioInfo{}, fmt.Errorf("ffprobe failed: %w: %s", err, msg)
+		}
+		return AudioInfo{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	var report struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+			BitRate    string `json:"bit_rate"`
+			Duration   string `json:"duration"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+			BitRate  string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(stdoutBuf.Bytes(), &report); err != nil {
+		return AudioInfo{}, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	if len(report.Streams) == 0 {
+		return AudioInfo{}, fmt.Errorf("no audio stream found")
+	}
+	st := report.Streams[0]
+
+	info := AudioInfo{
+		Codec:      st.CodecName,
+		SampleRate: atoiOrZero(st.SampleRate),
+		Channels:   st.Channels,
+		Bitrate:    atoiOrZero(st.BitRate),
+	}
+	if info.Bitrate == 0 {
+		// Some containers (Ogg, FLAC) only report the overall bitrate.
+		info.Bitrate = atoiOrZero(report.Format.BitRate)
+	}
+
+	// The container duration is the more reliable one; streams often lack
+	// it or carry an estimate.
+	seconds, err := strconv.ParseFloat(report.Format.Duration, 64)
+	if err != nil {
+		seconds, err = strconv.ParseFloat(st.Duration, 64)
+	}
+	if err != nil || seconds <= 0 {
+		return AudioInfo{}, fmt.Errorf("no duration reported (duration=%q)", report.Format.Duration)
+	}
+	info.Duration = time.Duration(seconds * float64(time.Second))
+	return info, nil
+}
+
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,172 @@
+// Package history keeps the play log: one entry for every track that went
+// on air, persisted so it survives restarts.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/fileutil"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// Play is one track that went on air.
+type Play struct {
+	TrackID      int64            `json:"trackId"`
+	Checksum     string           `json:"checksum"`
+	Title        string           `json:"title"`
+	Artist       string           `json:"artist,omitempty"`
+	FilePath     string           `json:"filePath,omitempty"`
+	PlaylistID   int64            `json:"playlistId"`
+	PlaylistName string           `json:"playlistName"`
+	Tag          playlist.TimeTag `json:"tag"`
+	StartedAt    time.Time        `json:"startedAt"`
+	Duration     float64          `json:"durationSeconds"` // how long it actually played
+	Skipped      bool             `json:"skipped"`
+	Listeners    int              `json:"listeners"` // listener count when it started
+}
+
+// Query selects a page of plays. Zero From/To leave that end of the range
+// open; a non-positive Limit returns everything from Offset on.
+type Query struct {
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+// Log keeps the plays in memory and persists them to a JSON file. Record
+// only marks the log dirty; Run does the writing, so the broadcast loop never
+// waits on the disk.
+type Log struct {
+	mu        sync.Mutex
+	plays     []Play // oldest first
+	path      string
+	retention time.Duration
+
+	dirty  chan struct{} // holds one pending save request
+	saveMu sync.Mutex    // serialises writes so the newest snapshot lands last
+}
+
+// New creates a Log backed by the given file and loads any plays already
+// stored there. Plays older than retention are discarded; a zero retention
+// keeps them forever.
+func New(path string, retention time.Duration) (*Log, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create play history directory %q: %w", dir, err)
+	}
+
+	l := &Log{path: path, retention: retention, dirty: make(chan struct{}, 1)}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read play history %q: %w", path, err)
+	default:
+		if err := json.Unmarshal(raw, &l.plays); err != nil {
+			return nil, fmt.Errorf("failed to parse play history %q: %w", path, err)
+		}
+	}
+	sort.SliceStable(l.plays, func(i, j int) bool { return l.plays[i].StartedAt.Before(l.plays[j].StartedAt) })
+	l.pruneUnsafe(time.Now())
+	return l, nil
+}
+
+// Record appends a finished play and schedules a save. Several plays
+// recorded before the writer catches up are saved together.
+func (l *Log) Record(p Play) {
+	l.mu.Lock()
+	l.plays = append(l.plays, p)
+	l.pruneUnsafe(time.Now())
+	l.mu.Unlock()
+
+	select {
+	case l.dirty <- struct{}{}:
+	default: // a save is already pending and will include this play
+	}
+}
+
+// Run writes the log to disk whenever plays were recorded. It blocks until
+// ctx is cancelled, then saves once more so no recorded play is lost.
+func (l *Log) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			l.Flush()
+			return
+		case <-l.dirty:
+			l.Flush()
+		}
+	}
+}
+
+// Flush writes the log to disk now.
+func (l *Log) Flush() {
+	if err := l.save(); err != nil {
+		slog.Error("Failed to save play history", "error", err)
+	}
+}
+
+// List returns the plays matching q, most recent first, together with the
+// number of plays in the range before paging.
+func (l *Log) List(q Query) ([]Play, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matched []Play
+	for i := len(l.plays) - 1; i >= 0; i-- {
+		p := l.plays[i]
+		if !q.From.IsZero() && p.StartedAt.Before(q.From) {
+			break // older plays only from here on
+		}
+		if !q.To.IsZero() && !p.StartedAt.Before(q.To) {
+			continue
+		}
+		matched = append(matched, p)
+	}
+
+	total := len(matched)
+	if q.Offset >= total {
+		return []Play{}, total
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
+
+// pruneUnsafe drops plays that started before the retention window. The
+// caller must hold l.mu.
+func (l *Log) pruneUnsafe(now time.Time) {
+	if l.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-l.retention)
+	i := sort.Search(len(l.plays), func(i int) bool { return !l.plays[i].StartedAt.Before(cutoff) })
+	l.plays = l.plays[i:]
+}
+
+// save writes the log to disk atomically (write to temp file, then rename).
+func (l *Log) save() error {
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+
+	l.mu.Lock()
+	jsonBytes, err := json.Marshal(l.plays)
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal play history: %w", err)
+	}
+
+	return fileutil.WriteAtomic(l.path, jsonBytes)
+}
@@ -0,0 +1,327 @@
+package listener
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/fileutil"
+)
+
+// Session is one listener connection to a stream mount.
+type Session struct {
+	ID            string     `json:"id"`
+	IP            string     `json:"ip"`
+	UserAgent     string     `json:"userAgent"`
+	Mount         string     `json:"mount"`
+	StartedAt     time.Time  `json:"startedAt"`
+	EndedAt       *time.Time `json:"endedAt,omitempty"`
+	BytesSent     int64      `json:"bytesSent"`
+	DroppedFrames uint64     `json:"droppedFrames"`
+}
+
+// Duration returns how long the session lasted, or has lasted so far if it
+// is still active.
+func (s Session) Duration(now time.Time) time.Duration {
+	end := now
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	return end.Sub(s.StartedAt)
+}
+
+// Live is the handle for an active session. The stream handler updates its
+// counters while serving audio and calls End when the connection closes.
+type Live struct {
+	tracker *Tracker
+	session Session
+	kick    func()
+
+	bytesSent atomic.Int64
+	dropped   atomic.Uint64
+	ended     atomic.Bool
+}
+
+// ID returns the session id.
+func (l *Live) ID() string {
+	return l.session.ID
+}
+
+// AddBytes records n more bytes delivered to the listener.
+func (l *Live) AddBytes(n int) {
+	l.bytesSent.Add(int64(n))
+}
+
+// SetDropped records the total number of frames dropped for the listener.
+func (l *Live) SetDropped(n uint64) {
+	l.dropped.Store(n)
+}
+
+// End closes the session and moves it to the history.
+func (l *Live) End() {
+	if l.ended.Swap(true) {
+		return
+	}
+	l.tracker.finish(l)
+}
+
+func (l *Live) snapshot() Session {
+	s := l.session
+	s.BytesSent = l.bytesSent.Load()
+	s.DroppedFrames = l.dropped.Load()
+	return s
+}
+
+// DayStats aggregates listening time for one calendar day.
+type DayStats struct {
+	Date           string  `json:"date"` // YYYY-MM-DD in the station timezone
+	Sessions       int     `json:"sessions"`
+	ListeningHours float64 `json:"listeningHours"`
+}
+
+// Tracker keeps the active sessions in memory and persists finished ones to
+// a JSON file so listening statistics survive restarts. Ending a session
+// only marks the history dirty; Run does the writing, so many listeners
+// leaving at once cost one write instead of one each.
+type Tracker struct {
+	mu        sync.Mutex
+	active    map[string]*Live
+	finished  []Session
+	path      string
+	retention time.Duration
+
+	dirty  chan struct{} // holds one pending save request
+	saveMu sync.Mutex    // serialises writes so the newest snapshot lands last
+}
+
+// NewTracker creates a Tracker backed by the given history file and loads
+// any sessions already stored there. Finished sessions older than retention
+// are discarded; a zero retention keeps them forever.
+func NewTracker(path string, retention time.Duration) (*Tracker, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create listener history directory %q: %w", dir, err)
+	}
+
+	t := &Tracker{
+		active:    make(map[string]*Live),
+		path:      path,
+		retention: retention,
+		dirty:     make(chan struct{}, 1),
+	}
+
+	raw, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read listener history %q: %w", path, err)
+	default:
+		if err := json.Unmarshal(raw, &t.finished); err != nil {
+			return nil, fmt.Errorf("failed to parse listener history %q: %w", path, err)
+		}
+	}
+	t.pruneUnsafe(time.Now())
+	return t, nil
+}
+
+// Start opens a session. kick is called when the DJ disconnects the
+// listener through Kick.
+func (t *Tracker) Start(ip, userAgent, mount string, kick func()) *Live {
+	l := &Live{
+		tracker: t,
+		kick:    kick,
+		session: Session{
+			ID:        newSessionID(),
+			IP:        ip,
+			UserAgent: userAgent,
+			Mount:     mount,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+	t.mu.Lock()
+	t.active[l.session.ID] = l
+	t.mu.Unlock()
+	return l
+}
+
+func (t *Tracker) finish(l *Live) {
+	s := l.snapshot()
+	ended := time.Now().UTC()
+	s.EndedAt = &ended
+
+	t.mu.Lock()
+	delete(t.active, s.ID)
+	t.finished = append(t.finished, s)
+	t.pruneUnsafe(ended)
+	t.mu.Unlock()
+
+	select {
+	case t.dirty <- struct{}{}:
+	default: // a save is already pending and will include this session
+	}
+}
+
+// Run writes the session history to disk whenever sessions have ended. It
+// blocks until ctx is cancelled, then saves once more.
+func (t *Tracker) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			t.Flush()
+			return
+		case <-t.dirty:
+			t.Flush()
+		}
+	}
+}
+
+// Flush writes the session history to disk now.
+func (t *Tracker) Flush() {
+	if err := t.save(); err != nil {
+		slog.Error("Failed to save listener history", "error", err)
+	}
+}
+
+// Active returns a snapshot of the currently connected sessions, oldest
+// first.
+func (t *Tracker) Active() []Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Session, 0, len(t.active))
+	for _, l := range t.active {
+		out = append(out, l.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// History returns up to limit finished sessions, most recent first. A
+// non-positive limit returns all of them.
+func (t *Tracker) History(limit int) []Session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(t.finished)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	out := make([]Session, 0, n)
+	for i := len(t.finished) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, t.finished[i])
+	}
+	return out
+}
+
+// Kick disconnects the listener with the given session id.
+func (t *Tracker) Kick(id string) error {
+	t.mu.Lock()
+	l, ok := t.active[id]
+	t.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("listener session %q not found", id)
+	}
+	if l.kick != nil {
+		l.kick()
+	}
+	return nil
+}
+
+// DailyStats returns listening hours for each of the last days calendar
+// days in loc, oldest first, including time from sessions still active.
+// Sessions spanning midnight are split between the days they cover.
+func (t *Tracker) DailyStats(days int, loc *time.Location) []DayStats {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	first := today.AddDate(0, 0, -(days - 1))
+
+	stats := make([]DayStats, days)
+	for i := range stats {
+		stats[i].Date = first.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	t.mu.Lock()
+	sessions := make([]Session, 0, len(t.finished)+len(t.active))
+	sessions = append(sessions, t.finished...)
+	for _, l := range t.active {
+		sessions = append(sessions, l.snapshot())
+	}
+	t.mu.Unlock()
+
+	for _, s := range sessions {
+		start := s.StartedAt.In(loc)
+		end := start.Add(s.Duration(now))
+		if end.Before(first) {
+			continue
+		}
+		for i := range stats {
+			dayStart := first.AddDate(0, 0, i)
+			dayEnd := dayStart.AddDate(0, 0, 1)
+			if !start.Before(dayStart) && start.Before(dayEnd) {
+				stats[i].Sessions++
+			}
+			lo, hi := start, end
+			if lo.Before(dayStart) {
+				lo = dayStart
+			}
+			if hi.After(dayEnd) {
+				hi = dayEnd
+			}
+			if hi.After(lo) {
+				stats[i].ListeningHours += hi.Sub(lo).Hours()
+			}
+		}
+	}
+	return stats
+}
+
+// pruneUnsafe drops finished sessions that ended before the retention
+// window. The caller must hold t.mu.
+func (t *Tracker) pruneUnsafe(now time.Time) {
+	if t.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-t.retention)
+	keep := t.finished[:0]
+	for _, s := range t.finished {
+		if s.EndedAt != nil && s.EndedAt.Before(cutoff) {
+			continue
+		}
+		keep = append(keep, s)
+	}
+	t.finished = keep
+}
+
+// save writes the finished sessions to disk atomically (write to temp file,
+// then rename).
+func (t *Tracker) save() error {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	jsonBytes, err := json.Marshal(t.finished)
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal listener history: %w", err)
+	}
+
+	return fileutil.WriteAtomic(t.path, jsonBytes)
+}
+
+func newSessionID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b[:])
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 )
 
 // TrackLibrary is the single source of truth for all known tracks. Every track
@@ -48,6 +49,7 @@ func (lib *TrackLibrary) Add(t *Track) (existing *Track, added bool) {
 	}
 
 	t.ID = lib.allocateID()
+	t.AddedAt = time.Now().UTC()
 	lib.tracks[t.Checksum] = t
 	lib.byID[t.ID] = t
 	return t, true
@@ -73,10 +75,30 @@ func (lib *TrackLibrary) AddOrUpdate(t *Track) *Track {
 		if t.Format != "" {
 			ex.Format = t.Format
 		}
+		// Fill in a duration that was never measured, e.g. for tracks
+		// scanned before durations were read.
+		if ex.Duration == 0 && t.Duration > 0 {
+			ex.Duration = t.Duration
+		}
+		// Stream properties describe the file itself, so a fresh probe
+		// always wins.
+		if t.Codec != "" || t.Corrupt {
+			if ex.Codec == "" && t.Duration > 0 {
+				// First probe: it is the authoritative duration.
+				ex.Duration = t.Duration
+			}
+			ex.Codec = t.Codec
+			ex.Bitrate = t.Bitrate
+			ex.SampleRate = t.SampleRate
+			ex.Channels = t.Channels
+			ex.Corrupt = t.Corrupt
+			ex.ProbeError = t.ProbeError
+		}
 		return ex
 	}
 
 	t.ID = lib.allocateID()
+	t.AddedAt = time.Now().UTC()
 	lib.tracks[t.Checksum] = t
 	lib.byID[t.ID] = t
 	return t
@@ -97,6 +119,7 @@ func (lib *TrackLibrary) BulkAdd(tracks []*Track) int {
 			continue
 		}
 		t.ID = lib.allocateID()
+		t.AddedAt = time.Now().UTC()
 		lib.tracks[t.Checksum] = t
 		lib.byID[t.ID] = t
 		added++
@@ -198,6 +221,21 @@ func (lib *TrackLibrary) Update(id int64, upd TrackUpdate) (*Track, error) {
 	return t, nil
 }
 
+// SetLoudness stores the loudness analysis result for the track identified
+// by the given ID.
+func (lib *TrackLibrary) SetLoudness(id int64, integrated, truePeak float64) (*Track, error) {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	t, ok := lib.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("track %d not found in library", id)
+	}
+	t.Loudness = &integrated
+	t.TruePeak = &truePeak
+	return t, nil
+}
+
 // TrackUpdate holds optional field updates for a track. Nil fields are not
 // applied.
 type TrackUpdate struct {
@@ -290,6 +328,22 @@ func (lib *TrackLibrary) listUnsafe() []*Track {
 	return result
 }
 
+// Filter returns the tracks for which keep returns true, sorted by ID. keep
+// runs under the library's read lock, so it must not call back into the
+// library.
+func (lib *TrackLibrary) Filter(keep func(*Track) bool) []*Track {
+	lib.mu.RLock()
+	defer lib.mu.RUnlock()
+
+	var result []*Track
+	for _, t := range lib.listUnsafe() {
+		if keep(t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
 // Resolve returns the canonical *Track pointers for the given checksums. Any
 // checksum that is not found in the library is silently skipped, and a warning
 // is logged. This is used when loading playlists to turn persisted checksum
@@ -4,19 +4,23 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"sync"
 	"time"
 )
 
-// MasterPlaylist holds collections of playlists organised by time-of-day tags.
+// MasterPlaylist holds collections of playlists organised by time slots.
 // The radio service plays from the active playlist, which is determined by the
-// current time and the tag assignments.
+// current time, the slot table and the slot assignments.
 type MasterPlaylist struct {
-	mu        sync.RWMutex
-	Morning   []*Playlist `json:"morning"`
-	Afternoon []*Playlist `json:"afternoon"`
-	Evening   []*Playlist `json:"evening"`
-	Night     []*Playlist `json:"night"`
+	mu sync.RWMutex
+
+	// slots is the station's slot table; playlists maps each slot name to
+	// the playlists assigned to it.
+	slots     []TimeSlot
+	playlists map[TimeTag][]*Playlist
+	// overrides replace the normal schedule on particular dates.
+	overrides []ScheduleOverride
 
 	// Library is the single source of truth for all track data. Every track
 	// referenced by any playlist must exist in this library.
@@ -24,8 +28,12 @@ type MasterPlaylist struct {
 
 	// activeTag is the tag currently being used for playback.
 	activeTag TimeTag
-	// activePlaylistIndex tracks which playlist within the active tag's slice
-	// is currently being played.
+	// activeDay is the day of the week whose schedule the active tag plays.
+	activeDay time.Weekday
+	// activeOverride is the ID of the schedule override in force, or zero.
+	activeOverride int64
+	// activePlaylistIndex tracks which of the playlists scheduled for the
+	// active tag and day is currently being played.
 	activePlaylistIndex int
 
 	// location is the IANA timezone used for time-tag resolution.
@@ -34,18 +42,19 @@ type MasterPlaylist struct {
 	// timezoneName stores the IANA name so it can be persisted and returned
 	// via the API (e.g. "Asia/Tokyo", "America/New_York").
 	timezoneName string
+
+	// clock supplies the current time; nil means SystemClock.
+	clock Clock
+
+	// resume is the checkpoint restored at startup until its offset has been
+	// taken by the broadcaster.
+	resume *PlaybackPosition
 }
 
-// NewMasterPlaylist creates a new MasterPlaylist with empty slices for each
-// time tag and a fresh TrackLibrary.
+// NewMasterPlaylist creates a new MasterPlaylist with the default time slots
+// and a fresh TrackLibrary.
 func NewMasterPlaylist() *MasterPlaylist {
-	return &MasterPlaylist{
-		Morning:   make([]*Playlist, 0),
-		Afternoon: make([]*Playlist, 0),
-		Evening:   make([]*Playlist, 0),
-		Night:     make([]*Playlist, 0),
-		Library:   NewTrackLibrary(),
-	}
+	return NewMasterPlaylistWithLibrary(NewTrackLibrary())
 }
 
 // NewMasterPlaylistWithLibrary creates a new MasterPlaylist using an existing
@@ -55,10 +64,8 @@ func NewMasterPlaylistWithLibrary(lib *TrackLibrary) *MasterPlaylist {
 		lib = NewTrackLibrary()
 	}
 	return &MasterPlaylist{
-		Morning:   make([]*Playlist, 0),
-		Afternoon: make([]*Playlist, 0),
-		Evening:   make([]*Playlist, 0),
-		Night:     make([]*Playlist, 0),
+		slots:     DefaultTimeSlots(),
+		playlists: make(map[TimeTag][]*Playlist),
 		Library:   lib,
 	}
 }
@@ -73,46 +80,51 @@ func (mp *MasterPlaylist) GetPlaylists(tag TimeTag) []*Playlist {
 // getPlaylistsUnsafe returns the slice pointer for the given tag without
 // locking. The caller must hold at least a read lock.
 func (mp *MasterPlaylist) getPlaylistsUnsafe(tag TimeTag) []*Playlist {
-	switch tag {
-	case TagMorning:
-		return mp.Morning
-	case TagAfternoon:
-		return mp.Afternoon
-	case TagEvening:
-		return mp.Evening
-	case TagNight:
-		return mp.Night
-	default:
-		return nil
-	}
+	return mp.playlists[tag]
 }
 
 // setPlaylistsUnsafe replaces the slice for the given tag. The caller must
 // hold a write lock.
 func (mp *MasterPlaylist) setPlaylistsUnsafe(tag TimeTag, pls []*Playlist) {
-	switch tag {
-	case TagMorning:
-		mp.Morning = pls
-	case TagAfternoon:
-		mp.Afternoon = pls
-	case TagEvening:
-		mp.Evening = pls
-	case TagNight:
-		mp.Night = pls
+	if len(pls) == 0 {
+		delete(mp.playlists, tag)
+		return
+	}
+	mp.playlists[tag] = pls
+}
+
+// tagsUnsafe returns every tag playlists can be found under: the slot names
+// in table order, followed by any tag that still holds playlists without a
+// matching slot (e.g. from a hand-edited store file). The caller must hold
+// at least a read lock.
+func (mp *MasterPlaylist) tagsUnsafe() []TimeTag {
+	tags := make([]TimeTag, 0, len(mp.slots))
+	known := make(map[TimeTag]bool, len(mp.slots))
+	for _, s := range mp.slots {
+		tags = append(tags, s.Name)
+		known[s.Name] = true
+	}
+	var extra []TimeTag
+	for tag, pls := range mp.playlists {
+		if !known[tag] && len(pls) > 0 {
+			extra = append(extra, tag)
+		}
 	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i] < extra[j] })
+	return append(tags, extra...)
 }
 
 // AssignPlaylist adds a playlist to the specified time tag. If a playlist with
 // the same ID already exists under that tag it is replaced. The playlist's
 // library reference is set to this master playlist's library.
 func (mp *MasterPlaylist) AssignPlaylist(tag TimeTag, pl *Playlist) error {
-	if !IsValidTimeTag(string(tag)) {
-		return fmt.Errorf("invalid time tag: %s", tag)
-	}
-
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
+	if !mp.hasSlotUnsafe(tag) {
+		return fmt.Errorf("invalid time tag: %s", tag)
+	}
+
 	// Update the playlist's own tag to match.
 	pl.Tag = tag
 
@@ -137,10 +149,6 @@ func (mp *MasterPlaylist) AssignPlaylist(tag TimeTag, pl *Playlist) error {
 // RemovePlaylist removes a playlist with the given ID from the specified tag.
 // Returns an error if the playlist is not found under that tag.
 func (mp *MasterPlaylist) RemovePlaylist(tag TimeTag, playlistID int64) error {
-	if !IsValidTimeTag(string(tag)) {
-		return fmt.Errorf("invalid time tag: %s", tag)
-	}
-
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
@@ -171,7 +179,7 @@ func (mp *MasterPlaylist) FindPlaylistByID(id int64) (*Playlist, TimeTag, error)
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, p := range mp.getPlaylistsUnsafe(tag) {
 			if p.ID == id {
 				return p, tag, nil
@@ -187,7 +195,7 @@ func (mp *MasterPlaylist) AllPlaylists() []*Playlist {
 	defer mp.mu.RUnlock()
 
 	var all []*Playlist
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		all = append(all, mp.getPlaylistsUnsafe(tag)...)
 	}
 	return all
@@ -200,7 +208,7 @@ func (mp *MasterPlaylist) AllTracks() []*Track {
 	defer mp.mu.RUnlock()
 
 	var tracks []*Track
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, pl := range mp.getPlaylistsUnsafe(tag) {
 			pl.mu.RLock()
 			tracks = append(tracks, pl.Tracks...)
@@ -225,7 +233,7 @@ func (mp *MasterPlaylist) AllTracksDeduped() []*Track {
 	// Fallback for when no library is set (shouldn't happen in normal operation).
 	seen := make(map[string]bool)
 	var tracks []*Track
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, pl := range mp.getPlaylistsUnsafe(tag) {
 			pl.mu.RLock()
 			for _, t := range pl.Tracks {
@@ -248,7 +256,7 @@ func (mp *MasterPlaylist) RemoveTrackFromAll(checksum string) int {
 	defer mp.mu.RUnlock()
 
 	total := 0
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, pl := range mp.getPlaylistsUnsafe(tag) {
 			total += pl.RemoveTracksByChecksum(checksum)
 		}
@@ -256,7 +264,8 @@ func (mp *MasterPlaylist) RemoveTrackFromAll(checksum string) int {
 	return total
 }
 
-// TimeTagForHour returns the appropriate TimeTag for the given hour (0-23).
+// TimeTagForHour returns the TimeTag of the default slot table for the given
+// hour (0-23).
 //
 //	Morning:   06:00 – 11:59
 //	Afternoon: 12:00 – 17:59
@@ -275,14 +284,14 @@ func TimeTagForHour(hour int) TimeTag {
 	}
 }
 
-// CurrentTimeTag returns the TimeTag for the current time in UTC.
-// Prefer CurrentTimeTagIn when a specific timezone is desired.
+// CurrentTimeTag returns the default slot for the current time in UTC.
+// Prefer MasterPlaylist.CurrentTag, which uses the station's own slots.
 func CurrentTimeTag() TimeTag {
 	return TimeTagForHour(time.Now().UTC().Hour())
 }
 
-// CurrentTimeTagIn returns the TimeTag for the current time in the given
-// location. If loc is nil, UTC is used.
+// CurrentTimeTagIn returns the default slot for the current time in the
+// given location. If loc is nil, UTC is used.
 func CurrentTimeTagIn(loc *time.Location) TimeTag {
 	if loc == nil {
 		loc = time.UTC
@@ -290,15 +299,33 @@ func CurrentTimeTagIn(loc *time.Location) TimeTag {
 	return TimeTagForHour(time.Now().In(loc).Hour())
 }
 
-// ResolveActiveTag determines which time tag should be active based on the
-// current time and the master playlist's configured timezone. It returns the
-// tag and whether a change from the previous active tag occurred.
+// ResolveActiveTag determines which time slot should be active based on the
+// current time, the slot table and the master playlist's configured timezone,
+// along with the day of the week whose schedule it plays and the schedule
+// override in force. It returns the tag and whether a change occurred: a
+// different tag, an override starting or ending, or the same tag on a day
+// with different playlists scheduled. The tag is empty if no slot covers the
+// current time.
 func (mp *MasterPlaylist) ResolveActiveTag() (TimeTag, bool) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	tag := CurrentTimeTagIn(mp.location)
-	changed := tag != mp.activeTag
+	loc := mp.location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := mp.nowUnsafe().In(loc)
+	tag, day := slotAndDayAt(mp.slots, now)
+	var overrideID int64
+	if o, _ := overrideAt(mp.overrides, now); o != nil {
+		overrideID = o.ID
+	}
+	changed := tag != mp.activeTag || overrideID != mp.activeOverride
+	if !changed && day != mp.activeDay {
+		changed = !samePlaylists(mp.scheduledUnsafe(tag, mp.activeDay), mp.scheduledUnsafe(tag, day))
+	}
+	mp.activeDay = day
+	mp.activeOverride = overrideID
 	if changed {
 		mp.activeTag = tag
 		mp.activePlaylistIndex = 0
@@ -306,17 +333,68 @@ func (mp *MasterPlaylist) ResolveActiveTag() (TimeTag, bool) {
 	return tag, changed
 }
 
+func samePlaylists(a, b []*Playlist) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SetClock replaces the clock used for time-tag resolution.
+func (mp *MasterPlaylist) SetClock(c Clock) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.clock = c
+}
+
+// Now returns the current time according to the playlist's clock.
+func (mp *MasterPlaylist) Now() time.Time {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.nowUnsafe()
+}
+
+// nowUnsafe is Now without locking. The caller must hold at least a read
+// lock.
+func (mp *MasterPlaylist) nowUnsafe() time.Time {
+	if mp.clock == nil {
+		return SystemClock.Now()
+	}
+	return mp.clock.Now()
+}
+
+// CurrentTag returns the time slot for the current time in the playlist's
+// timezone, without changing the active tag. It is empty if no slot covers
+// the current time.
+func (mp *MasterPlaylist) CurrentTag() TimeTag {
+	now := mp.Now().In(mp.Location())
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return slotAt(mp.slots, ClockTimeOf(now))
+}
+
 // SetActiveTag explicitly sets the active tag (e.g. for testing or manual
-// override).
+// override). Today's schedule for the tag is used.
 func (mp *MasterPlaylist) SetActiveTag(tag TimeTag) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
+	loc := mp.location
+	if loc == nil {
+		loc = time.UTC
+	}
 	mp.activeTag = tag
+	mp.activeDay = mp.nowUnsafe().In(loc).Weekday()
 	mp.activePlaylistIndex = 0
 }
 
-// SetTimezone sets the IANA timezone used for time-tag resolution.
-// An empty string resets to UTC. Returns an error if the name is invalid.
+// SetTimezone sets the IANA timezone the slot table is read in. An empty
+// string resets to UTC. Returns an error if the name is invalid. The active
+// slot changes on the next ResolveActiveTag.
 func (mp *MasterPlaylist) SetTimezone(name string) error {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
@@ -366,24 +444,25 @@ func (mp *MasterPlaylist) ActiveTag() TimeTag {
 }
 
 // ActivePlaylist returns the playlist that should currently be playing based on
-// the active tag. If no playlists are assigned to the active tag, it falls back
-// through the tags in order: the current tag, then whichever tag has playlists.
-// Returns nil and an error if no playlists exist at all.
+// the active tag and day. If no playlists are scheduled for them, it falls
+// back to whichever tag has playlists scheduled for the active day. Returns
+// nil and an error if nothing is scheduled at all.
 func (mp *MasterPlaylist) ActivePlaylist() (*Playlist, error) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
 	// First try the active tag.
-	if pls := mp.getPlaylistsUnsafe(mp.activeTag); len(pls) > 0 {
+	if pls := mp.activePlaylistsUnsafe(); len(pls) > 0 {
 		if mp.activePlaylistIndex >= len(pls) {
 			mp.activePlaylistIndex = 0
 		}
 		return pls[mp.activePlaylistIndex], nil
 	}
 
-	// Fallback: find any tag that has playlists.
-	for _, tag := range ValidTimeTags {
-		if pls := mp.getPlaylistsUnsafe(tag); len(pls) > 0 {
+	// Fallback: find any tag with playlists scheduled for the active day,
+	// so weekday and seasonal playlists still keep to their days.
+	for _, tag := range mp.tagsUnsafe() {
+		if pls := mp.scheduledUnsafe(tag, mp.activeDay); len(pls) > 0 {
 			return pls[0], nil
 		}
 	}
@@ -404,13 +483,13 @@ func (mp *MasterPlaylist) Next() (*Track, *Playlist, error) {
 	var effectiveTag TimeTag
 	var playlists []*Playlist
 
-	if pls := mp.getPlaylistsUnsafe(mp.activeTag); len(pls) > 0 {
+	if pls := mp.activePlaylistsUnsafe(); len(pls) > 0 {
 		effectiveTag = mp.activeTag
 		playlists = pls
 	} else {
-		// Fallback to any tag with playlists.
-		for _, tag := range ValidTimeTags {
-			if pls := mp.getPlaylistsUnsafe(tag); len(pls) > 0 {
+		// Fallback to any tag with playlists scheduled for the active day.
+		for _, tag := range mp.tagsUnsafe() {
+			if pls := mp.scheduledUnsafe(tag, mp.activeDay); len(pls) > 0 {
 				effectiveTag = tag
 				playlists = pls
 				break
@@ -459,7 +538,7 @@ func (mp *MasterPlaylist) AdvanceToNextPlaylist() (*Playlist, error) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
-	pls := mp.getPlaylistsUnsafe(mp.activeTag)
+	pls := mp.activePlaylistsUnsafe()
 	if len(pls) == 0 {
 		return nil, fmt.Errorf("no playlists for tag %s", mp.activeTag)
 	}
@@ -476,7 +555,7 @@ func (mp *MasterPlaylist) RemoveDeletedTracks() int {
 	defer mp.mu.RUnlock()
 
 	removed := 0
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, pl := range mp.getPlaylistsUnsafe(tag) {
 			pl.mu.Lock()
 			alive := make([]*Track, 0, len(pl.Tracks))
@@ -510,26 +589,25 @@ func (mp *MasterPlaylist) Summary() map[TimeTag]int {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 
-	return map[TimeTag]int{
-		TagMorning:   len(mp.Morning),
-		TagAfternoon: len(mp.Afternoon),
-		TagEvening:   len(mp.Evening),
-		TagNight:     len(mp.Night),
+	summary := make(map[TimeTag]int)
+	for _, tag := range mp.tagsUnsafe() {
+		summary[tag] = len(mp.getPlaylistsUnsafe(tag))
 	}
+	return summary
 }
 
 // activePlaylistUnsafe returns the active playlist without locking.
 // The caller must hold mp.mu in at least read mode.
 func (mp *MasterPlaylist) activePlaylistUnsafe() *Playlist {
-	if pls := mp.getPlaylistsUnsafe(mp.activeTag); len(pls) > 0 {
+	if pls := mp.activePlaylistsUnsafe(); len(pls) > 0 {
 		idx := mp.activePlaylistIndex
 		if idx >= len(pls) {
 			idx = 0
 		}
 		return pls[idx]
 	}
-	for _, tag := range ValidTimeTags {
-		if pls := mp.getPlaylistsUnsafe(tag); len(pls) > 0 {
+	for _, tag := range mp.tagsUnsafe() {
+		if pls := mp.scheduledUnsafe(tag, mp.activeDay); len(pls) > 0 {
 			return pls[0]
 		}
 	}
@@ -627,7 +705,7 @@ func (mp *MasterPlaylist) TotalTracks() int {
 	defer mp.mu.RUnlock()
 
 	total := 0
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		for _, pl := range mp.getPlaylistsUnsafe(tag) {
 			total += pl.Count()
 		}
@@ -649,7 +727,7 @@ func (mp *MasterPlaylist) IsEmpty() bool {
 	mp.mu.RLock()
 	defer mp.mu.RUnlock()
 
-	for _, tag := range ValidTimeTags {
+	for _, tag := range mp.tagsUnsafe() {
 		if len(mp.getPlaylistsUnsafe(tag)) > 0 {
 			return false
 		}
@@ -0,0 +1,305 @@
+package playlist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduleOverride replaces the normal schedule for a date or a range of
+// dates, such as a holiday or an event weekend. While it is active, every
+// slot that one of its playlists is assigned to plays only the override's
+// playlists; other slots keep their normal playlists. Playlists that belong
+// to an override are held back from the normal schedule, which makes them
+// seasonal: they only play while one of their overrides is active.
+//
+// Dates are "YYYY-MM-DD", or "MM-DD" for a Yearly override that repeats
+// every year. Both ends are inclusive and a yearly range may run over New
+// Year (e.g. 12-24 to 01-01). A yearly 02-29 falls on 03-01 in years
+// without a leap day.
+type ScheduleOverride struct {
+	ID        int64   `json:"id"`
+	Name      string  `json:"name"`
+	Start     string  `json:"start"`
+	End       string  `json:"end"`
+	Yearly    bool    `json:"yearly,omitempty"`
+	Playlists []int64 `json:"playlists"`
+}
+
+// parseOverrideDate parses a date of an override into its year (zero for
+// yearly overrides), month and day.
+func parseOverrideDate(s string, yearly bool) (int, time.Month, int, error) {
+	layout := "2006-01-02"
+	if yearly {
+		layout = "01-02"
+	}
+	t, err := time.Parse(layout, strings.TrimSpace(s))
+	if err != nil {
+		if yearly {
+			return 0, 0, 0, fmt.Errorf("invalid override date %q: expected MM-DD", s)
+		}
+		return 0, 0, 0, fmt.Errorf("invalid override date %q: expected YYYY-MM-DD", s)
+	}
+	if yearly {
+		return 0, t.Month(), t.Day(), nil
+	}
+	return t.Year(), t.Month(), t.Day(), nil
+}
+
+// Validate checks the name, dates and playlists of the override.
+func (o ScheduleOverride) Validate() error {
+	if strings.TrimSpace(o.Name) == "" {
+		return fmt.Errorf("invalid override: name is required")
+	}
+	sy, sm, sd, err := parseOverrideDate(o.Start, o.Yearly)
+	if err != nil {
+		return err
+	}
+	ey, em, ed, err := parseOverrideDate(o.End, o.Yearly)
+	if err != nil {
+		return err
+	}
+	if !o.Yearly && time.Date(ey, em, ed, 0, 0, 0, 0, time.UTC).Before(time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)) {
+		return fmt.Errorf("invalid override: end date is before start date")
+	}
+	if len(o.Playlists) == 0 {
+		return fmt.Errorf("invalid override: at least one playlist is required")
+	}
+	return nil
+}
+
+// occurrence returns the start of the override's run that covers t, and the
+// moment it ends (midnight after its last day), both in t's location. ok is
+// false if the override is not active at t.
+func (o ScheduleOverride) occurrence(t time.Time) (start, end time.Time, ok bool) {
+	sy, sm, sd, err := parseOverrideDate(o.Start, o.Yearly)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	ey, em, ed, err := parseOverrideDate(o.End, o.Yearly)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	loc := t.Location()
+	span := func(startYear, endYear int) (time.Time, time.Time) {
+		return time.Date(startYear, sm, sd, 0, 0, 0, 0, loc), time.Date(endYear, em, ed+1, 0, 0, 0, 0, loc)
+	}
+
+	candidates := [][2]int{{sy, ey}}
+	if o.Yearly {
+		y := t.Year()
+		if em < sm || (em == sm && ed < sd) {
+			// Runs over New Year: this winter's run or last winter's.
+			candidates = [][2]int{{y - 1, y}, {y, y + 1}}
+		} else {
+			candidates = [][2]int{{y, y}}
+		}
+	}
+	for _, c := range candidates {
+		s, e := span(c[0], c[1])
+		if !t.Before(s) && t.Before(e) {
+			return s, e, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// overrideAt returns the override active at t and when it ends. Where
+// overrides overlap the shortest one wins, so a single holiday can sit
+// inside a season; ties go to the override listed first.
+func overrideAt(overrides []ScheduleOverride, t time.Time) (*ScheduleOverride, time.Time) {
+	var best *ScheduleOverride
+	var bestEnd time.Time
+	var bestLen time.Duration
+	for i := range overrides {
+		s, e, ok := overrides[i].occurrence(t)
+		if ok && (best == nil || e.Sub(s) < bestLen) {
+			best, bestEnd, bestLen = &overrides[i], e, e.Sub(s)
+		}
+	}
+	return best, bestEnd
+}
+
+// seasonalUnsafe reports whether a playlist belongs to any override and so
+// is kept out of the normal schedule. The caller must hold at least a read
+// lock.
+func (mp *MasterPlaylist) seasonalUnsafe(id int64) bool {
+	for _, o := range mp.overrides {
+		for _, pid := range o.Playlists {
+			if pid == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overridePlaylistsUnsafe returns the playlists of tag that the active
+// override plays on day, or nil if it does not cover the tag. The caller
+// must hold at least a read lock.
+func (mp *MasterPlaylist) overridePlaylistsUnsafe(tag TimeTag, day time.Weekday) []*Playlist {
+	o := mp.overrideByIDUnsafe(mp.activeOverride)
+	if o == nil {
+		return nil
+	}
+	var pls []*Playlist
+	for _, pl := range mp.getPlaylistsUnsafe(tag) {
+		if !pl.Days.Has(day) {
+			continue
+		}
+		for _, pid := range o.Playlists {
+			if pid == pl.ID {
+				pls = append(pls, pl)
+				break
+			}
+		}
+	}
+	return pls
+}
+
+func (mp *MasterPlaylist) overrideByIDUnsafe(id int64) *ScheduleOverride {
+	if id == 0 {
+		return nil
+	}
+	for i := range mp.overrides {
+		if mp.overrides[i].ID == id {
+			return &mp.overrides[i]
+		}
+	}
+	return nil
+}
+
+// checkOverridePlaylistsUnsafe makes sure every playlist an override lists
+// exists. The caller must hold at least a read lock.
+func (mp *MasterPlaylist) checkOverridePlaylistsUnsafe(o ScheduleOverride) error {
+	known := make(map[int64]bool)
+	for _, tag := range mp.tagsUnsafe() {
+		for _, pl := range mp.getPlaylistsUnsafe(tag) {
+			known[pl.ID] = true
+		}
+	}
+	for _, id := range o.Playlists {
+		if !known[id] {
+			return fmt.Errorf("playlist %d not found", id)
+		}
+	}
+	return nil
+}
+
+// Overrides returns a copy of the schedule overrides.
+func (mp *MasterPlaylist) Overrides() []ScheduleOverride {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	out := make([]ScheduleOverride, len(mp.overrides))
+	for i, o := range mp.overrides {
+		o.Playlists = append([]int64(nil), o.Playlists...)
+		out[i] = o
+	}
+	return out
+}
+
+// AddOverride validates an override, gives it an ID and adds it to the
+// schedule.
+func (mp *MasterPlaylist) AddOverride(o ScheduleOverride) (ScheduleOverride, error) {
+	if err := o.Validate(); err != nil {
+		return ScheduleOverride{}, err
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if err := mp.checkOverridePlaylistsUnsafe(o); err != nil {
+		return ScheduleOverride{}, err
+	}
+	var maxID int64
+	for _, ex := range mp.overrides {
+		if ex.ID > maxID {
+			maxID = ex.ID
+		}
+	}
+	o.ID = maxID + 1
+	o.Playlists = append([]int64(nil), o.Playlists...)
+	mp.overrides = append(mp.overrides, o)
+	return o, nil
+}
+
+// UpdateOverride replaces the override with the given ID.
+func (mp *MasterPlaylist) UpdateOverride(id int64, o ScheduleOverride) (ScheduleOverride, error) {
+	o.ID = id
+	if err := o.Validate(); err != nil {
+		return ScheduleOverride{}, err
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	ex := mp.overrideByIDUnsafe(id)
+	if ex == nil {
+		return ScheduleOverride{}, fmt.Errorf("override %d not found", id)
+	}
+	if err := mp.checkOverridePlaylistsUnsafe(o); err != nil {
+		return ScheduleOverride{}, err
+	}
+	o.Playlists = append([]int64(nil), o.Playlists...)
+	*ex = o
+	return o, nil
+}
+
+// RemoveOverride deletes the override with the given ID.
+func (mp *MasterPlaylist) RemoveOverride(id int64) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for i, o := range mp.overrides {
+		if o.ID == id {
+			mp.overrides = append(mp.overrides[:i:i], mp.overrides[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("override %d not found", id)
+}
+
+// ForgetPlaylistInOverrides drops a deleted playlist from every override
+// that lists it.
+func (mp *MasterPlaylist) ForgetPlaylistInOverrides(playlistID int64) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for i := range mp.overrides {
+		ids := mp.overrides[i].Playlists[:0]
+		for _, id := range mp.overrides[i].Playlists {
+			if id != playlistID {
+				ids = append(ids, id)
+			}
+		}
+		mp.overrides[i].Playlists = ids
+	}
+}
+
+// ActiveOverride returns the override the schedule was last resolved with,
+// or nil if the normal schedule applies.
+func (mp *MasterPlaylist) ActiveOverride() *ScheduleOverride {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	o := mp.overrideByIDUnsafe(mp.activeOverride)
+	if o == nil {
+		return nil
+	}
+	cp := *o
+	cp.Playlists = append([]int64(nil), o.Playlists...)
+	return &cp
+}
+
+// CurrentOverride returns the override active now in the playlist's
+// timezone and when it ends, or nil if the normal schedule applies.
+func (mp *MasterPlaylist) CurrentOverride() (*ScheduleOverride, time.Time) {
+	now := mp.Now().In(mp.Location())
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	o, end := overrideAt(mp.overrides, now)
+	if o == nil {
+		return nil, time.Time{}
+	}
+	cp := *o
+	cp.Playlists = append([]int64(nil), o.Playlists...)
+	return &cp, end
+}
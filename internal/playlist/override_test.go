@@ -0,0 +1,80 @@
+package playlist
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestOverrideOccurrence(t *testing.T) {
+	winter := ScheduleOverride{Name: "Winter break", Start: "12-24", End: "01-01", Yearly: true}
+	leapDay := ScheduleOverride{Name: "Leap day", Start: "02-29", End: "02-29", Yearly: true}
+	once := ScheduleOverride{Name: "New Year 2026", Start: "2025-12-31", End: "2026-01-01"}
+
+	tests := []struct {
+		name       string
+		o          ScheduleOverride
+		t          time.Time
+		ok         bool
+		start, end time.Time
+	}{
+		{"day before the winter break", winter, date(2025, 12, 23, 23, 59), false, time.Time{}, time.Time{}},
+		{"first day of the winter break", winter, date(2025, 12, 24, 0, 0), true, date(2025, 12, 24, 0, 0), date(2026, 1, 2, 0, 0)},
+		{"New Year's Eve", winter, date(2025, 12, 31, 23, 59), true, date(2025, 12, 24, 0, 0), date(2026, 1, 2, 0, 0)},
+		{"New Year's Day", winter, date(2026, 1, 1, 23, 59), true, date(2025, 12, 24, 0, 0), date(2026, 1, 2, 0, 0)},
+		{"after the winter break", winter, date(2026, 1, 2, 0, 0), false, time.Time{}, time.Time{}},
+		{"next winter break", winter, date(2026, 12, 30, 12, 0), true, date(2026, 12, 24, 0, 0), date(2027, 1, 2, 0, 0)},
+		{"middle of the year", winter, date(2026, 7, 1, 12, 0), false, time.Time{}, time.Time{}},
+
+		{"leap day in a leap year", leapDay, date(2028, 2, 29, 9, 0), true, date(2028, 2, 29, 0, 0), date(2028, 3, 1, 0, 0)},
+		{"day after the leap day", leapDay, date(2028, 3, 1, 9, 0), false, time.Time{}, time.Time{}},
+		// Without a 29 February the date rolls over to 1 March.
+		{"end of February in a common year", leapDay, date(2027, 2, 28, 9, 0), false, time.Time{}, time.Time{}},
+		{"1 March in a common year", leapDay, date(2027, 3, 1, 9, 0), true, date(2027, 3, 1, 0, 0), date(2027, 3, 2, 0, 0)},
+
+		{"one-off range over New Year", once, date(2026, 1, 1, 12, 0), true, date(2025, 12, 31, 0, 0), date(2026, 1, 2, 0, 0)},
+		{"one-off range does not repeat", once, date(2026, 12, 31, 12, 0), false, time.Time{}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := tt.o.occurrence(tt.t)
+			if ok != tt.ok || !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Fatalf("occurrence = %s..%s %v, want %s..%s %v", start, end, ok, tt.start, tt.end, tt.ok)
+			}
+		})
+	}
+}
+
+func TestOverrideAtShortestWins(t *testing.T) {
+	overrides := []ScheduleOverride{
+		{ID: 1, Name: "Christmas season", Start: "12-01", End: "01-06", Yearly: true},
+		{ID: 2, Name: "Christmas Day", Start: "12-25", End: "12-25", Yearly: true},
+		{ID: 3, Name: "Boxing Day", Start: "2026-12-26", End: "2026-12-26"},
+		{ID: 4, Name: "Also Boxing Day", Start: "12-26", End: "12-26", Yearly: true},
+	}
+	tests := []struct {
+		t   time.Time
+		id  int64
+		end time.Time
+	}{
+		{date(2026, 11, 30, 12, 0), 0, time.Time{}},
+		{date(2026, 12, 24, 12, 0), 1, date(2027, 1, 7, 0, 0)},
+		{date(2026, 12, 25, 0, 0), 2, date(2026, 12, 26, 0, 0)},
+		// Equal lengths: the override listed first wins.
+		{date(2026, 12, 26, 12, 0), 3, date(2026, 12, 27, 0, 0)},
+		{date(2027, 1, 1, 12, 0), 1, date(2027, 1, 7, 0, 0)},
+	}
+	for _, tt := range tests {
+		o, end := overrideAt(overrides, tt.t)
+		var id int64
+		if o != nil {
+			id = o.ID
+		}
+		if id != tt.id || !end.Equal(tt.end) {
+			t.Fatalf("overrideAt(%s) = %d until %s, want %d until %s", tt.t.Format("2006-01-02"), id, end, tt.id, tt.end)
+		}
+	}
+}
@@ -6,9 +6,10 @@ import (
 	"sync"
 )
 
-// TimeTag represents a time-of-day category for playlist scheduling.
+// TimeTag is the name of a time slot that playlists are scheduled under.
 type TimeTag string
 
+// Names of the default time slots.
 const (
 	TagMorning   TimeTag = "morning"
 	TagAfternoon TimeTag = "afternoon"
@@ -16,19 +17,6 @@ const (
 	TagNight     TimeTag = "night"
 )
 
-// ValidTimeTags contains all valid TimeTag values.
-var ValidTimeTags = []TimeTag{TagMorning, TagAfternoon, TagEvening, TagNight}
-
-// IsValidTimeTag returns true if the given string is a valid TimeTag.
-func IsValidTimeTag(s string) bool {
-	for _, t := range ValidTimeTags {
-		if string(t) == s {
-			return true
-		}
-	}
-	return false
-}
-
 // lastPlaylistID is a global counter for generating unique playlist IDs.
 var (
 	playlistIDMu   sync.Mutex
@@ -62,8 +50,17 @@ type Playlist struct {
 	Tag                  TimeTag  `json:"tag"`
 	Tracks               []*Track `json:"tracks"`
 	CurrentTrackChecksum string   `json:"currentTrackChecksum,omitempty"`
-	currentIndex         int
-	library              *TrackLibrary // optional reference; when set, tracks are validated against it
+	// CrossfadeSeconds overrides the station-wide crossfade for tracks played
+	// from this playlist. Nil means "use the station default".
+	CrossfadeSeconds *float64 `json:"crossfadeSeconds,omitempty"`
+	// Days limits the playlist to some days of the week within its time
+	// slot. Zero means every day.
+	Days Weekdays `json:"days,omitempty"`
+	// Rule makes this a smart playlist whose tracks are every library track
+	// the rule matches. Nil for a regular playlist.
+	Rule         *SmartRule `json:"rule,omitempty"`
+	currentIndex int
+	library      *TrackLibrary // optional reference; when set, tracks are validated against it
 }
 
 // SetLibrary associates this playlist with a TrackLibrary. When set, AddTrack
@@ -155,6 +152,30 @@ func (p *Playlist) ResolveFromLibrary(lib *TrackLibrary) {
 	p.relocateCursorUnsafe()
 }
 
+// Crossfade returns the playlist's crossfade override in seconds and whether
+// one is set.
+func (p *Playlist) Crossfade() (float64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.CrossfadeSeconds == nil {
+		return 0, false
+	}
+	return *p.CrossfadeSeconds, true
+}
+
+// SetCrossfade sets the playlist's crossfade override. Pass nil to fall back
+// to the station default.
+func (p *Playlist) SetCrossfade(seconds *float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seconds == nil {
+		p.CrossfadeSeconds = nil
+		return
+	}
+	v := *seconds
+	p.CrossfadeSeconds = &v
+}
+
 // NewPlaylist creates a new empty Playlist with the given name and tag.
 func NewPlaylist(name string, tag TimeTag) *Playlist {
 	return &Playlist{
@@ -452,20 +473,22 @@ func (p *Playlist) Shuffle() {
 }
 
 // Next returns the next track in the playlist and advances the internal
-// cursor. Returns nil and false if the playlist is empty.
+// cursor. Returns nil and false if the playlist has no playable track.
 func (p *Playlist) Next() (*Track, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if len(p.Tracks) == 0 {
-		return nil, false
+	// Corrupt tracks, which libraries scanned by older versions may still
+	// hold, are passed over.
+	for range p.Tracks {
+		track := p.Tracks[p.currentIndex]
+		p.currentIndex = (p.currentIndex + 1) % len(p.Tracks)
+		if !track.Corrupt {
+			p.CurrentTrackChecksum = track.Checksum
+			return track, true
+		}
 	}
-
-	track := p.Tracks[p.currentIndex]
-	p.CurrentTrackChecksum = track.Checksum
-	p.currentIndex = (p.currentIndex + 1) % len(p.Tracks)
-
-	return track, true
+	return nil, false
 }
 
 // Current returns the track that was most recently returned by Next().
@@ -566,6 +589,8 @@ func (p *Playlist) Clone(newName string) *Playlist {
 		Tag:                  p.Tag,
 		Tracks:               tracks,
 		CurrentTrackChecksum: p.CurrentTrackChecksum,
+		CrossfadeSeconds:     p.CrossfadeSeconds,
+		Rule:                 p.Rule.clone(),
 		currentIndex:         p.currentIndex,
 		library:              p.library,
 	}
@@ -0,0 +1,117 @@
+package playlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/fileutil"
+)
+
+// PlaybackPosition is a checkpoint of what was on air, so a restart can
+// resume the same track at the same point instead of starting over.
+type PlaybackPosition struct {
+	Tag           TimeTag   `json:"tag"`
+	PlaylistID    int64     `json:"playlistId"`
+	TrackChecksum string    `json:"trackChecksum"`
+	Offset        float64   `json:"offsetSeconds"`
+	SavedAt       time.Time `json:"savedAt"`
+}
+
+// positionPath returns the checkpoint file that sits next to the store file,
+// e.g. data/playlists.position.json for data/playlists.json.
+func (s *Store) positionPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".position.json"
+}
+
+// SavePosition writes the playback checkpoint atomically. It is kept out of
+// the main store file so frequent checkpoints do not rewrite the library.
+func (s *Store) SavePosition(pos PlaybackPosition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jsonBytes, err := json.MarshalIndent(pos, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal playback position: %w", err)
+	}
+
+	path := s.positionPath()
+	return fileutil.WriteAtomic(path, jsonBytes)
+}
+
+// LoadPosition reads the playback checkpoint. It returns nil without an
+// error if none has been saved yet.
+func (s *Store) LoadPosition() (*PlaybackPosition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, err := os.ReadFile(s.positionPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playback position: %w", err)
+	}
+	var pos PlaybackPosition
+	if err := json.Unmarshal(raw, &pos); err != nil {
+		return nil, fmt.Errorf("failed to parse playback position: %w", err)
+	}
+	return &pos, nil
+}
+
+// RestorePosition points the master playlist back at a checkpointed track:
+// the playlist it came from becomes active again and its next track is the
+// checkpointed one. The offset is handed out once through TakeResumeOffset.
+// Nothing changes, and false is returned, if the schedule has moved on to a
+// different time tag or the playlist or track no longer exist.
+func (mp *MasterPlaylist) RestorePosition(pos *PlaybackPosition) bool {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if pos == nil || pos.Tag != mp.activeTag {
+		return false
+	}
+	for i, pl := range mp.activePlaylistsUnsafe() {
+		if pl.ID != pos.PlaylistID {
+			continue
+		}
+		if !pl.cueTrack(pos.TrackChecksum) {
+			return false
+		}
+		mp.activePlaylistIndex = i
+		mp.resume = pos
+		return true
+	}
+	return false
+}
+
+// TakeResumeOffset returns where to start track if it is the one restored
+// by RestorePosition, and zero otherwise. The restored offset is consumed by
+// the first call.
+func (mp *MasterPlaylist) TakeResumeOffset(track *Track) time.Duration {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	pos := mp.resume
+	mp.resume = nil
+	if pos == nil || track == nil || track.Checksum != pos.TrackChecksum {
+		return 0
+	}
+	return time.Duration(pos.Offset * float64(time.Second))
+}
+
+// cueTrack makes the track with the given checksum the next one returned by
+// Next. It reports whether the track is in the playlist.
+func (p *Playlist) cueTrack(checksum string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, t := range p.Tracks {
+		if t.Checksum == checksum {
+			p.currentIndex = i
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package playlist
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -16,16 +17,25 @@ type ScanResult struct {
 	// Errors maps file paths to errors encountered while processing them.
 	// These are non-fatal; the scan continues past individual file failures.
 	Errors map[string]error
+	// Corrupt holds the files that failed to probe, flagged with
+	// Track.Corrupt. They are left out of Tracks so they never reach the
+	// library or a playlist.
+	Corrupt []*Track
 }
 
 // ScanMusicDirectory walks the given directory recursively and creates Track
 // objects for every supported audio file found. Tracks are sorted by file path
 // in the result. Individual file errors (checksum failures, unreadable files,
 // etc.) are collected in ScanResult.Errors rather than aborting the whole scan.
+// When prober is non-nil new files are probed for their duration and stream
+// properties, and files that fail to probe are returned in Corrupt instead
+// of Tracks. Files already probed in known (matched by checksum) reuse that
+// result rather than running ffprobe again; a file in known that fails its
+// first probe has its entry there flagged Corrupt. known may be nil.
 //
 // NOTE: The returned tracks have ID 0. Use ScanIntoLibrary to both scan and
 // register tracks with stable IDs in a TrackLibrary.
-func ScanMusicDirectory(musicDir string) (*ScanResult, error) {
+func ScanMusicDirectory(musicDir string, prober Prober, known *TrackLibrary) (*ScanResult, error) {
 	info, err := os.Stat(musicDir)
 	if err != nil {
 		return nil, fmt.Errorf("cannot access music directory %q: %w", musicDir, err)
@@ -63,6 +73,20 @@ func ScanMusicDirectory(musicDir string) (*ScanResult, error) {
 			return nil
 		}
 
+		if prober != nil && !reuseProbe(track, known) {
+			_ = ProbeTrack(context.Background(), track, prober)
+		}
+		if track.Corrupt {
+			if known != nil && known.Get(track.Checksum) != nil {
+				// Added before it was probed: flag the library entry so
+				// it stops playing and is reported as corrupt.
+				track = known.AddOrUpdate(track)
+			}
+			result.Corrupt = append(result.Corrupt, track)
+			slog.Warn("Skipping corrupt track", "path", path, "error", track.ProbeError)
+			return nil
+		}
+
 		result.Tracks = append(result.Tracks, track)
 		return nil
 	})
@@ -79,12 +103,34 @@ func ScanMusicDirectory(musicDir string) (*ScanResult, error) {
 	slog.Info("Music directory scan complete",
 		"directory", musicDir,
 		"tracks_found", len(result.Tracks),
+		"corrupt", len(result.Corrupt),
 		"errors", len(result.Errors),
 	)
 
 	return result, nil
 }
 
+// reuseProbe copies the probe result of the same file from known onto track,
+// so files are only probed once. It reports false if the file has never been
+// probed.
+func reuseProbe(track *Track, known *TrackLibrary) bool {
+	if known == nil {
+		return false
+	}
+	ex := known.Get(track.Checksum)
+	if ex == nil || (ex.Codec == "" && !ex.Corrupt) {
+		return false
+	}
+	track.Duration = ex.Duration
+	track.Codec = ex.Codec
+	track.Bitrate = ex.Bitrate
+	track.SampleRate = ex.SampleRate
+	track.Channels = ex.Channels
+	track.Corrupt = ex.Corrupt
+	track.ProbeError = ex.ProbeError
+	return true
+}
+
 // ScanIntoLibrary scans the music directory and adds all discovered tracks to
 // the provided TrackLibrary. Tracks that already exist in the library (matched
 // by checksum) are updated with the current file path but otherwise left
@@ -92,8 +138,8 @@ func ScanMusicDirectory(musicDir string) (*ScanResult, error) {
 //
 // Returns the scan result (with the library-canonical Track pointers) and the
 // number of newly added tracks.
-func ScanIntoLibrary(musicDir string, lib *TrackLibrary) (*ScanResult, int, error) {
-	scanResult, err := ScanMusicDirectory(musicDir)
+func ScanIntoLibrary(musicDir string, lib *TrackLibrary, prober Prober) (*ScanResult, int, error) {
+	scanResult, err := ScanMusicDirectory(musicDir, prober, lib)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -121,8 +167,8 @@ func ScanIntoLibrary(musicDir string, lib *TrackLibrary) (*ScanResult, int, erro
 // FindOrphanedTracks compares a fresh scan of the music directory against the
 // tracks already present in the library. It returns tracks that exist on disk
 // but are not yet in the library (matched by checksum).
-func FindOrphanedTracks(musicDir string, master *MasterPlaylist) ([]*Track, error) {
-	scanResult, err := ScanMusicDirectory(musicDir)
+func FindOrphanedTracks(musicDir string, master *MasterPlaylist, prober Prober) ([]*Track, error) {
+	scanResult, err := ScanMusicDirectory(musicDir, prober, master.Library)
 	if err != nil {
 		return nil, err
 	}
@@ -159,8 +205,8 @@ func FindOrphanedTracks(musicDir string, master *MasterPlaylist) ([]*Track, erro
 // FindOrphanedTracksFromLibrary returns tracks on disk that are not in the
 // library. Unlike FindOrphanedTracks, this works directly against the library
 // and does not require a MasterPlaylist.
-func FindOrphanedTracksFromLibrary(musicDir string, lib *TrackLibrary) ([]*Track, error) {
-	scanResult, err := ScanMusicDirectory(musicDir)
+func FindOrphanedTracksFromLibrary(musicDir string, lib *TrackLibrary, prober Prober) ([]*Track, error) {
+	scanResult, err := ScanMusicDirectory(musicDir, prober, lib)
 	if err != nil {
 		return nil, err
 	}
@@ -184,8 +230,8 @@ func FindOrphanedTracksFromLibrary(musicDir string, lib *TrackLibrary) ([]*Track
 // to the master playlist's library, and creates a single playlist containing
 // all of them. The playlist is tagged with the current time-of-day tag. This
 // is used for first-run initialisation when no saved playlist exists.
-func BuildDefaultPlaylist(musicDir string) (*Playlist, error) {
-	scanResult, err := ScanMusicDirectory(musicDir)
+func BuildDefaultPlaylist(musicDir string, prober Prober) (*Playlist, error) {
+	scanResult, err := ScanMusicDirectory(musicDir, prober, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan music directory: %w", err)
 	}
@@ -210,8 +256,8 @@ func BuildDefaultPlaylist(musicDir string) (*Playlist, error) {
 // BuildDefaultPlaylistWithLibrary scans the music directory, registers all
 // discovered tracks in the provided library with stable IDs, and creates a
 // playlist containing all of them. The playlist is linked to the library.
-func BuildDefaultPlaylistWithLibrary(musicDir string, lib *TrackLibrary) (*Playlist, error) {
-	scanResult, added, err := ScanIntoLibrary(musicDir, lib)
+func BuildDefaultPlaylistWithLibrary(musicDir string, lib *TrackLibrary, prober Prober) (*Playlist, error) {
+	scanResult, added, err := ScanIntoLibrary(musicDir, lib, prober)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan music directory: %w", err)
 	}
@@ -245,7 +291,7 @@ func BuildDefaultPlaylistWithLibrary(musicDir string, lib *TrackLibrary) (*Playl
 // currently on disk. It removes tracks whose files have been deleted (from both
 // the library and playlists) and returns newly discovered files as orphaned
 // tracks. This is the core of the hot-reload feature.
-func ReconcileTracks(musicDir string, master *MasterPlaylist) (orphaned []*Track, removedCount int, err error) {
+func ReconcileTracks(musicDir string, master *MasterPlaylist, prober Prober) (orphaned []*Track, removedCount int, err error) {
 	// First, remove tracks whose files no longer exist.
 	if master.Library != nil {
 		// Remove stale tracks from library; this gives us the list of removed.
@@ -269,7 +315,7 @@ func ReconcileTracks(musicDir string, master *MasterPlaylist) (orphaned []*Track
 	}
 
 	// Then find new files that aren't in the library.
-	orphaned, err = FindOrphanedTracks(musicDir, master)
+	orphaned, err = FindOrphanedTracks(musicDir, master, prober)
 	if err != nil {
 		return nil, removedCount, fmt.Errorf("failed to find orphaned tracks: %w", err)
 	}
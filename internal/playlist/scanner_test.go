@@ -0,0 +1,52 @@
+package playlist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+func TestScanFlagsKnownTrackThatFailsToProbe(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.mp3")
+	good := filepath.Join(dir, "good.mp3")
+	if err := os.WriteFile(broken, []byte("not really audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(good, []byte("some audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The broken file is already in the library but was never probed.
+	lib := NewTrackLibrary()
+	track, err := NewTrackFromFile(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, _ := lib.Add(track)
+
+	enc := ffmpeg.NewFakeEncoder("128k", 44100, 2)
+	enc.SetError(broken, errors.New("invalid data found when processing input"))
+
+	result, err := ScanMusicDirectory(dir, enc, lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Tracks) != 1 || result.Tracks[0].FilePath != good {
+		t.Fatalf("scan returned %d tracks, want only the good one", len(result.Tracks))
+	}
+	if len(result.Corrupt) != 1 || result.Corrupt[0].FilePath != broken {
+		t.Fatalf("scan reported %d corrupt tracks, want the broken one", len(result.Corrupt))
+	}
+
+	got := lib.Get(entry.Checksum)
+	if got == nil || got.ID != entry.ID {
+		t.Fatal("library entry was replaced or dropped")
+	}
+	if !got.Corrupt || got.ProbeError == "" {
+		t.Fatalf("library entry corrupt=%v error=%q, want it flagged", got.Corrupt, got.ProbeError)
+	}
+}
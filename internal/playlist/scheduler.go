@@ -11,6 +11,9 @@ import (
 type SchedulerEvent struct {
 	PreviousTag TimeTag
 	NewTag      TimeTag
+	Day         time.Weekday      // whose schedule NewTag plays
+	Override    *ScheduleOverride // nil when the normal schedule applies
+	HardStart   bool              // NewTag is a hard-start slot that has just begun
 	Playlist    *Playlist
 	Timestamp   time.Time
 }
@@ -21,18 +24,24 @@ type SchedulerCallback func(event SchedulerEvent)
 
 // Scheduler periodically checks the current time and compares it against the
 // active tag of a MasterPlaylist. When the time-of-day category changes (e.g.
-// morning → afternoon) the scheduler triggers a callback so the radio service
-// can switch to the appropriate playlist.
+// morning → afternoon), or a new day brings different playlists to the same
+// slot, the scheduler triggers a callback so the radio service can switch to
+// the appropriate playlist.
 type Scheduler struct {
 	mu       sync.RWMutex
 	master   *MasterPlaylist
 	callback SchedulerCallback
 	interval time.Duration
+	clock    Clock
 
 	// lastTag records the tag that was active on the most recent tick so that
 	// the callback only fires on transitions.
 	lastTag TimeTag
 	running bool
+
+	// replan wakes the loop to recompute the next slot boundary after the
+	// slot table or timezone may have changed.
+	replan chan struct{}
 }
 
 // NewScheduler creates a Scheduler that watches the given MasterPlaylist for
@@ -47,10 +56,21 @@ func NewScheduler(master *MasterPlaylist, callback SchedulerCallback, interval t
 		master:   master,
 		callback: callback,
 		interval: interval,
-		lastTag:  CurrentTimeTagIn(master.Location()),
+		clock:    SystemClock,
+		lastTag:  master.CurrentTag(),
+		replan:   make(chan struct{}, 1),
 	}
 }
 
+// SetClock replaces the clock driving the check interval and event
+// timestamps. It must be called before Start; the master playlist's own
+// clock is set separately with MasterPlaylist.SetClock.
+func (s *Scheduler) SetClock(c Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
 // Start begins the scheduler loop. It blocks until ctx is cancelled. The
 // scheduler fires an initial check immediately, then re-checks every interval.
 func (s *Scheduler) Start(ctx context.Context) {
@@ -73,16 +93,30 @@ func (s *Scheduler) Start(ctx context.Context) {
 	// correctly from the start.
 	s.check()
 
-	ticker := time.NewTicker(s.interval)
+	s.mu.RLock()
+	ticker := s.clock.NewTicker(s.interval)
+	s.mu.RUnlock()
 	defer ticker.Stop()
 
 	for {
+		// Besides the regular tick, wake up exactly when the next slot
+		// starts or ends so hard-start slots begin on time.
+		var boundary <-chan time.Time
+		if next := s.master.NextSlotBoundary(); !next.IsZero() {
+			s.mu.RLock()
+			boundary = s.clock.After(next.Sub(s.clock.Now()))
+			s.mu.RUnlock()
+		}
+
 		select {
 		case <-ctx.Done():
 			slog.Info("Scheduler stopping")
 			return
-		case <-ticker.C:
+		case <-ticker.C():
+			s.check()
+		case <-boundary:
 			s.check()
+		case <-s.replan:
 		}
 	}
 }
@@ -90,6 +124,11 @@ func (s *Scheduler) Start(ctx context.Context) {
 // check performs a single time-tag evaluation and fires the callback if a
 // transition occurred.
 func (s *Scheduler) check() {
+	// Rules such as "added in the last 30 days" change with time alone, so
+	// smart playlists are refreshed on every check, not only when the
+	// library changes.
+	s.master.RefreshSmartPlaylists()
+
 	newTag, changed := s.master.ResolveActiveTag()
 
 	if !changed {
@@ -99,11 +138,25 @@ func (s *Scheduler) check() {
 	s.mu.Lock()
 	previousTag := s.lastTag
 	s.lastTag = newTag
+	clock := s.clock
 	s.mu.Unlock()
 
+	day := s.master.ActiveDay()
+	override := s.master.ActiveOverride()
+	var overrideName string
+	if override != nil {
+		overrideName = override.Name
+	}
+	// Only cut the track when the slot is starting on time; a switch into
+	// a hard-start slot caused by a schedule edit mid-slot waits for the
+	// track to end like any other.
+	hardStart := newTag != previousTag && s.master.HardStartDue(newTag, s.interval)
 	slog.Info("Time-tag transition detected",
 		"previous", previousTag,
 		"new", newTag,
+		"day", day,
+		"override", overrideName,
+		"hard_start", hardStart,
 	)
 
 	// Attempt to resolve the playlist that will now be active.
@@ -121,8 +174,11 @@ func (s *Scheduler) check() {
 		s.callback(SchedulerEvent{
 			PreviousTag: previousTag,
 			NewTag:      newTag,
+			Day:         day,
+			Override:    override,
+			HardStart:   hardStart,
 			Playlist:    activePl,
-			Timestamp:   time.Now(),
+			Timestamp:   clock.Now(),
 		})
 	}
 }
@@ -154,4 +210,8 @@ func (s *Scheduler) SetMasterPlaylist(master *MasterPlaylist) {
 // the scheduler to re-evaluate immediately.
 func (s *Scheduler) ForceCheck() {
 	s.check()
+	select {
+	case s.replan <- struct{}{}:
+	default:
+	}
 }
@@ -0,0 +1,260 @@
+package playlist
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// at returns the given time in March 2026 (the 2nd is a Monday), UTC.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2026, 3, day, hour, minute, 0, 0, time.UTC)
+}
+
+// startScheduler runs a scheduler over master on a FakeClock set to now and
+// returns the clock, the events it fires and the first of them, which
+// resolves the slot the station starts in. The scheduler stops when the
+// test ends.
+func startScheduler(t *testing.T, master *MasterPlaylist, now time.Time) (*FakeClock, <-chan SchedulerEvent, SchedulerEvent) {
+	t.Helper()
+	clock := NewFakeClock(now)
+	master.SetClock(clock)
+
+	events := make(chan SchedulerEvent, 8)
+	s := NewScheduler(master, func(e SchedulerEvent) { events <- e }, time.Minute)
+	s.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	first := nextEvent(t, events)
+	waitForTimer(t, clock)
+	return clock, events, first
+}
+
+// waitForTimer blocks until the scheduler loop has asked the clock to wake
+// it at the next slot boundary. Its ticker exists by then, so from here on
+// moving the clock by at least a minute always reaches the loop.
+func waitForTimer(t *testing.T, c *FakeClock) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		n := len(c.timers)
+		c.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("scheduler never waited for the next slot boundary")
+}
+
+func nextEvent(t *testing.T, events <-chan SchedulerEvent) SchedulerEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("scheduler callback did not fire")
+		return SchedulerEvent{}
+	}
+}
+
+func noEvent(t *testing.T, events <-chan SchedulerEvent) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected transition %q -> %q on %s", e.PreviousTag, e.NewTag, e.Day)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// assign adds a new playlist to tag and returns it.
+func assign(t *testing.T, master *MasterPlaylist, name string, tag TimeTag) *Playlist {
+	t.Helper()
+	pl := NewPlaylist(name, tag)
+	if err := master.AssignPlaylist(tag, pl); err != nil {
+		t.Fatal(err)
+	}
+	return pl
+}
+
+func TestSchedulerFiresAtSlotBoundary(t *testing.T) {
+	master := NewMasterPlaylist()
+	afternoon := assign(t, master, "Afternoon", TagAfternoon)
+
+	clock, events, first := startScheduler(t, master, at(2, 11, 59))
+	if first.NewTag != TagMorning {
+		t.Fatalf("initial tag = %q, want %q", first.NewTag, TagMorning)
+	}
+
+	clock.Set(at(2, 12, 0))
+	e := nextEvent(t, events)
+	if e.PreviousTag != TagMorning || e.NewTag != TagAfternoon {
+		t.Fatalf("transition = %q -> %q, want %q -> %q", e.PreviousTag, e.NewTag, TagMorning, TagAfternoon)
+	}
+	if e.Playlist != afternoon {
+		t.Fatalf("playlist = %v, want the afternoon playlist", e.Playlist)
+	}
+	if e.HardStart {
+		t.Fatal("HardStart set for a slot that is not hard-start")
+	}
+	if got := master.ActiveTag(); got != TagAfternoon {
+		t.Fatalf("active tag = %q, want %q", got, TagAfternoon)
+	}
+}
+
+func TestSchedulerNoEventWithinSlot(t *testing.T) {
+	master := NewMasterPlaylist()
+	clock, events, _ := startScheduler(t, master, at(2, 9, 0))
+
+	clock.Set(at(2, 9, 5))
+	noEvent(t, events)
+}
+
+func TestSchedulerCustomSlotTable(t *testing.T) {
+	master := NewMasterPlaylist()
+	slots := []TimeSlot{
+		{Name: "breakfast", Start: 6 * 60, End: 10 * 60},
+		{Name: "drive", Start: 10 * 60, End: 15 * 60, HardStart: true},
+		{Name: "rest", Start: 15 * 60, End: 6 * 60},
+		// A short show inside the drive slot wins while it runs.
+		{Name: "news", Start: 12 * 60, End: 12*60 + 10},
+	}
+	if err := master.SetSlots(slots); err != nil {
+		t.Fatal(err)
+	}
+	drive := assign(t, master, "Drive", "drive")
+
+	clock, events, first := startScheduler(t, master, at(2, 9, 59))
+	if first.NewTag != "breakfast" {
+		t.Fatalf("initial tag = %q, want breakfast", first.NewTag)
+	}
+
+	steps := []struct {
+		at        time.Time
+		from, to  TimeTag
+		hardStart bool
+	}{
+		{at(2, 10, 0), "breakfast", "drive", true},
+		{at(2, 12, 0), "drive", "news", false},
+		// Back into drive after the news, mid-slot: no cut.
+		{at(2, 12, 10), "news", "drive", false},
+		{at(2, 15, 0), "drive", "rest", false},
+	}
+	for _, s := range steps {
+		clock.Set(s.at)
+		e := nextEvent(t, events)
+		if e.PreviousTag != s.from || e.NewTag != s.to || e.HardStart != s.hardStart {
+			t.Fatalf("at %s: %q -> %q hard start %v, want %q -> %q hard start %v",
+				s.at.Format("15:04"), e.PreviousTag, e.NewTag, e.HardStart, s.from, s.to, s.hardStart)
+		}
+		if s.to == "drive" && e.Playlist != drive {
+			t.Fatalf("at %s: playlist = %v, want the drive playlist", s.at.Format("15:04"), e.Playlist)
+		}
+	}
+}
+
+func TestSchedulerNewDayInSameSlot(t *testing.T) {
+	master := NewMasterPlaylist()
+	if err := master.SetSlots([]TimeSlot{{Name: "allday", Start: 0, End: 0}}); err != nil {
+		t.Fatal(err)
+	}
+	saturday := assign(t, master, "Saturday", "allday")
+	sunday := assign(t, master, "Sunday", "allday")
+	if err := master.SetPlaylistDays(saturday.ID, 1<<time.Saturday); err != nil {
+		t.Fatal(err)
+	}
+	if err := master.SetPlaylistDays(sunday.ID, 1<<time.Sunday); err != nil {
+		t.Fatal(err)
+	}
+
+	clock, events, first := startScheduler(t, master, at(7, 23, 58))
+	if first.Day != time.Saturday || first.Playlist != saturday {
+		t.Fatalf("initial schedule = %s %v, want Saturday's playlist", first.Day, first.Playlist)
+	}
+
+	clock.Set(at(8, 0, 0))
+	e := nextEvent(t, events)
+	if e.PreviousTag != "allday" || e.NewTag != "allday" {
+		t.Fatalf("transition = %q -> %q, want the same slot", e.PreviousTag, e.NewTag)
+	}
+	if e.Day != time.Sunday || e.Playlist != sunday {
+		t.Fatalf("schedule = %s %v, want Sunday's playlist", e.Day, e.Playlist)
+	}
+}
+
+func TestSchedulerOvernightSlotKeepsStartDay(t *testing.T) {
+	master := NewMasterPlaylist()
+	friday := assign(t, master, "Friday night", TagNight)
+	saturday := assign(t, master, "Saturday night", TagNight)
+	if err := master.SetPlaylistDays(friday.ID, 1<<time.Friday); err != nil {
+		t.Fatal(err)
+	}
+	if err := master.SetPlaylistDays(saturday.ID, 1<<time.Saturday); err != nil {
+		t.Fatal(err)
+	}
+
+	clock, events, first := startScheduler(t, master, at(6, 23, 0))
+	if first.Day != time.Friday || first.Playlist != friday {
+		t.Fatalf("initial schedule = %s %v, want Friday's night playlist", first.Day, first.Playlist)
+	}
+
+	// Past midnight Friday's night show carries on.
+	clock.Set(at(7, 1, 0))
+	noEvent(t, events)
+	if day := master.ActiveDay(); day != time.Friday {
+		t.Fatalf("active day = %s after midnight, want Friday", day)
+	}
+
+	clock.Set(at(7, 21, 0))
+	for {
+		e := nextEvent(t, events)
+		if e.NewTag == TagNight {
+			if e.Day != time.Saturday || e.Playlist != saturday {
+				t.Fatalf("schedule = %s %v, want Saturday's night playlist", e.Day, e.Playlist)
+			}
+			break
+		}
+	}
+}
+
+func TestSchedulerOverrideStartAndEnd(t *testing.T) {
+	master := NewMasterPlaylist()
+	normal := assign(t, master, "Normal", TagMorning)
+	holiday := assign(t, master, "Holiday", TagMorning)
+	if _, err := master.AddOverride(ScheduleOverride{
+		Name:      "Holiday",
+		Start:     "2026-03-05",
+		End:       "2026-03-05",
+		Playlists: []int64{holiday.ID},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock, events, first := startScheduler(t, master, at(4, 9, 0))
+	if first.Override != nil || first.Playlist != normal {
+		t.Fatalf("initial schedule = %v override %v, want the normal playlist", first.Playlist, first.Override)
+	}
+
+	clock.Set(at(5, 9, 0))
+	e := nextEvent(t, events)
+	if e.Override == nil || e.Override.Name != "Holiday" || e.Playlist != holiday {
+		t.Fatalf("on the holiday: playlist %v override %v, want the holiday playlist", e.Playlist, e.Override)
+	}
+
+	clock.Set(at(6, 9, 0))
+	e = nextEvent(t, events)
+	if e.Override != nil || e.Playlist != normal {
+		t.Fatalf("after the holiday: playlist %v override %v, want the normal playlist", e.Playlist, e.Override)
+	}
+}
@@ -0,0 +1,324 @@
+package playlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// minutesPerDay is the length of the daily slot cycle.
+const minutesPerDay = 24 * 60
+
+// ClockTime is a time of day with minute precision, stored as minutes since
+// midnight. It is written as "HH:MM" in JSON.
+type ClockTime int
+
+// ParseClockTime parses "HH:MM" (24-hour clock). "24:00" is accepted as an
+// alias for midnight so a slot can end at the end of the day.
+func ParseClockTime(s string) (ClockTime, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil || len(strings.TrimSpace(s)) != 5 {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+	if h == 24 && m == 0 {
+		return 0, nil
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM", s)
+	}
+	return ClockTime(h*60 + m), nil
+}
+
+// ClockTimeOf returns the time of day of t in its own location.
+func ClockTimeOf(t time.Time) ClockTime {
+	return ClockTime(t.Hour()*60 + t.Minute())
+}
+
+func (c ClockTime) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid time: expected an \"HH:MM\" string")
+	}
+	v, err := ParseClockTime(s)
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+// TimeSlot is a named block of the day that playlists are assigned to. The
+// slot runs from Start up to, but not including, End in the station
+// timezone. An End at or before Start wraps past midnight; equal times cover
+// the whole day.
+//
+// A HardStart slot begins exactly on time: the track on air when it starts
+// is cut. Other slots wait for the current track to finish.
+type TimeSlot struct {
+	Name      TimeTag   `json:"name"`
+	Start     ClockTime `json:"start"`
+	End       ClockTime `json:"end"`
+	HardStart bool      `json:"hardStart,omitempty"`
+}
+
+// Contains reports whether the time of day c falls inside the slot.
+func (s TimeSlot) Contains(c ClockTime) bool {
+	switch {
+	case s.Start < s.End:
+		return c >= s.Start && c < s.End
+	case s.Start > s.End:
+		return c >= s.Start || c < s.End
+	default:
+		return true
+	}
+}
+
+// Length returns how many minutes of the day the slot covers.
+func (s TimeSlot) Length() int {
+	if s.Start == s.End {
+		return minutesPerDay
+	}
+	return (int(s.End) - int(s.Start) + minutesPerDay) % minutesPerDay
+}
+
+// DefaultTimeSlots returns the slot table used until the station defines its
+// own: the classic morning, afternoon, evening and night blocks.
+func DefaultTimeSlots() []TimeSlot {
+	return []TimeSlot{
+		{Name: TagMorning, Start: 6 * 60, End: 12 * 60},
+		{Name: TagAfternoon, Start: 12 * 60, End: 18 * 60},
+		{Name: TagEvening, Start: 18 * 60, End: 21 * 60},
+		{Name: TagNight, Start: 21 * 60, End: 6 * 60},
+	}
+}
+
+// slotNamePattern restricts slot names to what is safe in URL paths.
+var slotNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// reservedSlotNames cannot be used because they clash with API routes.
+var reservedSlotNames = map[TimeTag]bool{"slots": true, "overrides": true}
+
+// ValidateTimeSlots checks a slot table: at least one slot, and every name
+// unique, lower-case and URL-safe. Slots may overlap; see slotAt.
+func ValidateTimeSlots(slots []TimeSlot) error {
+	if len(slots) == 0 {
+		return fmt.Errorf("invalid time slot: at least one slot is required")
+	}
+	seen := make(map[TimeTag]bool, len(slots))
+	for _, s := range slots {
+		if !slotNamePattern.MatchString(string(s.Name)) {
+			return fmt.Errorf("invalid time slot name %q: use up to 32 lower-case letters, digits, '-' or '_'", s.Name)
+		}
+		if reservedSlotNames[s.Name] {
+			return fmt.Errorf("invalid time slot name %q: the name is reserved", s.Name)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("time slot %q already exists", s.Name)
+		}
+		if s.Start < 0 || s.Start >= minutesPerDay || s.End < 0 || s.End >= minutesPerDay {
+			return fmt.Errorf("invalid time slot %q: times must be between 00:00 and 23:59", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+// nextSlotBoundary returns the first time after now, in now's location, at
+// which a slot starts or ends. It returns the zero time if there are no
+// slots.
+func nextSlotBoundary(slots []TimeSlot, now time.Time) time.Time {
+	var next time.Time
+	y, m, d := now.Date()
+	for _, s := range slots {
+		for _, c := range []ClockTime{s.Start, s.End} {
+			t := time.Date(y, m, d, 0, int(c), 0, 0, now.Location())
+			if !t.After(now) {
+				t = time.Date(y, m, d+1, 0, int(c), 0, 0, now.Location())
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next
+}
+
+// slotAt returns the slot covering the time of day c, or "" if there is a
+// gap in the table. Where slots overlap the shortest one wins, so a short
+// show can sit inside a longer block; ties go to the slot listed first.
+func slotAt(slots []TimeSlot, c ClockTime) TimeTag {
+	var best TimeTag
+	bestLen := minutesPerDay + 1
+	for _, s := range slots {
+		if s.Contains(c) && s.Length() < bestLen {
+			best, bestLen = s.Name, s.Length()
+		}
+	}
+	return best
+}
+
+// Slots returns a copy of the slot table.
+func (mp *MasterPlaylist) Slots() []TimeSlot {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return append([]TimeSlot(nil), mp.slots...)
+}
+
+// SlotNames returns the names of all slots in table order.
+func (mp *MasterPlaylist) SlotNames() []TimeTag {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	names := make([]TimeTag, len(mp.slots))
+	for i, s := range mp.slots {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// NextSlotBoundary returns when the slot table next changes which slot is
+// on air, at the latest: the next time any slot starts or ends.
+func (mp *MasterPlaylist) NextSlotBoundary() time.Time {
+	now := mp.Now().In(mp.Location())
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return nextSlotBoundary(mp.slots, now)
+}
+
+// HardStartDue reports whether the named slot is a hard-start slot that
+// began less than within ago, i.e. whether it is switching in at its start
+// time rather than because the schedule was edited in the middle of it.
+func (mp *MasterPlaylist) HardStartDue(tag TimeTag, within time.Duration) bool {
+	now := mp.Now().In(mp.Location())
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	i := mp.slotIndexUnsafe(tag)
+	if i < 0 || !mp.slots[i].HardStart {
+		return false
+	}
+	y, m, d := now.Date()
+	start := time.Date(y, m, d, 0, int(mp.slots[i].Start), 0, 0, now.Location())
+	if start.After(now) {
+		start = time.Date(y, m, d-1, 0, int(mp.slots[i].Start), 0, 0, now.Location())
+	}
+	return now.Sub(start) < within
+}
+
+// HasSlot reports whether a slot with the given name exists.
+func (mp *MasterPlaylist) HasSlot(tag TimeTag) bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.hasSlotUnsafe(tag)
+}
+
+func (mp *MasterPlaylist) hasSlotUnsafe(tag TimeTag) bool {
+	return mp.slotIndexUnsafe(tag) >= 0
+}
+
+// slotIndexUnsafe returns the position of the named slot in the table, or
+// -1. The caller must hold at least a read lock.
+func (mp *MasterPlaylist) slotIndexUnsafe(tag TimeTag) int {
+	for i, s := range mp.slots {
+		if s.Name == tag {
+			return i
+		}
+	}
+	return -1
+}
+
+// SetSlots replaces the whole slot table, e.g. when loading it from the
+// store. Slots that still have playlists assigned cannot be dropped.
+func (mp *MasterPlaylist) SetSlots(slots []TimeSlot) error {
+	if err := ValidateTimeSlots(slots); err != nil {
+		return err
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	keep := make(map[TimeTag]bool, len(slots))
+	for _, s := range slots {
+		keep[s.Name] = true
+	}
+	for _, s := range mp.slots {
+		if !keep[s.Name] && len(mp.getPlaylistsUnsafe(s.Name)) > 0 {
+			return fmt.Errorf("time slot %q still has playlists assigned", s.Name)
+		}
+	}
+	mp.slots = append([]TimeSlot(nil), slots...)
+	return nil
+}
+
+// AddSlot appends a new slot to the table.
+func (mp *MasterPlaylist) AddSlot(slot TimeSlot) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	slots := append(append([]TimeSlot(nil), mp.slots...), slot)
+	if err := ValidateTimeSlots(slots); err != nil {
+		return err
+	}
+	mp.slots = slots
+	return nil
+}
+
+// UpdateSlot replaces the slot called name with slot. If slot carries a new
+// name, the playlists assigned to the old one move with it.
+func (mp *MasterPlaylist) UpdateSlot(name TimeTag, slot TimeSlot) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	i := mp.slotIndexUnsafe(name)
+	if i < 0 {
+		return fmt.Errorf("time slot %q not found", name)
+	}
+	slots := append([]TimeSlot(nil), mp.slots...)
+	slots[i] = slot
+	if err := ValidateTimeSlots(slots); err != nil {
+		return err
+	}
+	mp.slots = slots
+
+	if slot.Name != name {
+		pls := mp.getPlaylistsUnsafe(name)
+		for _, pl := range pls {
+			pl.Tag = slot.Name
+		}
+		mp.setPlaylistsUnsafe(name, nil)
+		mp.setPlaylistsUnsafe(slot.Name, pls)
+		if mp.activeTag == name {
+			mp.activeTag = slot.Name
+		}
+	}
+	return nil
+}
+
+// RemoveSlot deletes a slot from the table. Its playlists must be moved or
+// removed first, and the last slot cannot be deleted.
+func (mp *MasterPlaylist) RemoveSlot(name TimeTag) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	i := mp.slotIndexUnsafe(name)
+	if i < 0 {
+		return fmt.Errorf("time slot %q not found", name)
+	}
+	if len(mp.getPlaylistsUnsafe(name)) > 0 {
+		return fmt.Errorf("time slot %q still has playlists assigned", name)
+	}
+	slots := append(append([]TimeSlot(nil), mp.slots[:i]...), mp.slots[i+1:]...)
+	if err := ValidateTimeSlots(slots); err != nil {
+		return err
+	}
+	mp.slots = slots
+	return nil
+}
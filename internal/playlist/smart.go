@@ -0,0 +1,358 @@
+package playlist
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SmartRule is the saved query of a smart playlist. Instead of a fixed list
+// of tracks, a smart playlist holds every library track the rule matches and
+// is refreshed whenever the library changes.
+//
+// Match is "all" (the default) when every condition must hold, or "any" when
+// one is enough. Corrupt tracks never match.
+type SmartRule struct {
+	Match      string          `json:"match,omitempty"`
+	Conditions []RuleCondition `json:"conditions"`
+}
+
+// RuleCondition compares one track field with a value, e.g. genre eq
+// "denpa", year gte 2005, artist in ["A", "B"], duration lt 300 or added
+// within_days 30.
+//
+// Text fields compare case-insensitively. Duration is in seconds, like
+// Track.Duration. Value is a string or number, or a list of them for "in".
+// A list may be []any, as decoded from JSON, or a typed slice such as
+// []string; Validate stores it as []any.
+type RuleCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value any    `json:"value"`
+}
+
+// Rule fields grouped by the kind of value they hold.
+var (
+	textRuleFields   = map[string]bool{"title": true, "artist": true, "album": true, "genre": true, "format": true, "codec": true}
+	numberRuleFields = map[string]bool{"year": true, "duration": true, "bitrate": true}
+	dateRuleFields   = map[string]bool{"added": true}
+)
+
+// Operators accepted for each kind of field.
+var (
+	textRuleOps   = map[string]bool{"eq": true, "ne": true, "contains": true, "in": true}
+	numberRuleOps = map[string]bool{"eq": true, "ne": true, "lt": true, "lte": true, "gt": true, "gte": true, "in": true}
+	dateRuleOps   = map[string]bool{"within_days": true}
+)
+
+// Validate checks that the rule has at least one condition and that every
+// condition uses a known field, an operator that suits it and a value of the
+// right type. Typed lists given to "in" are converted to []any in place.
+func (r *SmartRule) Validate() error {
+	switch r.Match {
+	case "", "all", "any":
+	default:
+		return fmt.Errorf("invalid rule: match must be \"all\" or \"any\"")
+	}
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("invalid rule: at least one condition is required")
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].validate(); err != nil {
+			return fmt.Errorf("invalid rule: condition %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (c *RuleCondition) validate() error {
+	var ops map[string]bool
+	switch {
+	case textRuleFields[c.Field]:
+		ops = textRuleOps
+	case numberRuleFields[c.Field]:
+		ops = numberRuleOps
+	case dateRuleFields[c.Field]:
+		ops = dateRuleOps
+	default:
+		return fmt.Errorf("unknown field %q", c.Field)
+	}
+	if !ops[c.Op] {
+		return fmt.Errorf("operator %q cannot be used with %s", c.Op, c.Field)
+	}
+
+	if c.Op == "in" {
+		list, ok := ruleList(c.Value)
+		if !ok || len(list) == 0 {
+			return fmt.Errorf("%s in: value must be a non-empty list", c.Field)
+		}
+		for _, v := range list {
+			if err := checkRuleValue(c.Field, v); err != nil {
+				return err
+			}
+		}
+		c.Value = list
+		return nil
+	}
+	return checkRuleValue(c.Field, c.Value)
+}
+
+// ruleList returns the list given to "in" as []any, converting a typed
+// slice set in code.
+func ruleList(v any) ([]any, bool) {
+	if list, ok := v.([]any); ok {
+		return list, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil, false
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+// checkRuleValue makes sure a single value suits the field.
+func checkRuleValue(field string, v any) error {
+	if textRuleFields[field] {
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: value must be a string", field)
+		}
+		return nil
+	}
+	n, ok := ruleNumber(v)
+	if !ok {
+		return fmt.Errorf("%s: value must be a number", field)
+	}
+	if dateRuleFields[field] && n < 0 {
+		return fmt.Errorf("%s: number of days cannot be negative", field)
+	}
+	return nil
+}
+
+// ruleNumber converts a decoded JSON number, or a Go number set in code, to
+// float64.
+func ruleNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// Matches reports whether the track satisfies the rule at time now. The rule
+// must have been validated.
+func (r *SmartRule) Matches(t *Track, now time.Time) bool {
+	if t.Corrupt {
+		return false
+	}
+	matchAny := r.Match == "any"
+	for _, c := range r.Conditions {
+		if c.matches(t, now) == matchAny {
+			return matchAny
+		}
+	}
+	return !matchAny
+}
+
+func (c RuleCondition) matches(t *Track, now time.Time) bool {
+	switch {
+	case textRuleFields[c.Field]:
+		return c.matchesText(trackText(t, c.Field))
+	case numberRuleFields[c.Field]:
+		return c.matchesNumber(trackNumber(t, c.Field))
+	case c.Field == "added":
+		days, _ := ruleNumber(c.Value)
+		cutoff := now.Add(-time.Duration(days * float64(24*time.Hour)))
+		// Tracks added before the library recorded the date never count
+		// as recent.
+		return !t.AddedAt.IsZero() && !t.AddedAt.Before(cutoff)
+	}
+	return false
+}
+
+func (c RuleCondition) matchesText(s string) bool {
+	switch c.Op {
+	case "eq":
+		return strings.EqualFold(s, c.Value.(string))
+	case "ne":
+		return !strings.EqualFold(s, c.Value.(string))
+	case "contains":
+		return strings.Contains(strings.ToLower(s), strings.ToLower(c.Value.(string)))
+	case "in":
+		for _, v := range c.Value.([]any) {
+			if strings.EqualFold(s, v.(string)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c RuleCondition) matchesNumber(n float64) bool {
+	if c.Op == "in" {
+		for _, v := range c.Value.([]any) {
+			if x, _ := ruleNumber(v); x == n {
+				return true
+			}
+		}
+		return false
+	}
+	x, _ := ruleNumber(c.Value)
+	switch c.Op {
+	case "eq":
+		return n == x
+	case "ne":
+		return n != x
+	case "lt":
+		return n < x
+	case "lte":
+		return n <= x
+	case "gt":
+		return n > x
+	case "gte":
+		return n >= x
+	}
+	return false
+}
+
+func trackText(t *Track, field string) string {
+	switch field {
+	case "title":
+		return t.Title
+	case "artist":
+		return t.Artist
+	case "album":
+		return t.Album
+	case "genre":
+		return t.Genre
+	case "format":
+		return strings.TrimPrefix(t.Format, ".")
+	case "codec":
+		return t.Codec
+	}
+	return ""
+}
+
+func trackNumber(t *Track, field string) float64 {
+	switch field {
+	case "year":
+		return float64(t.Year)
+	case "duration":
+		return float64(t.Duration)
+	case "bitrate":
+		return float64(t.Bitrate)
+	}
+	return 0
+}
+
+// clone returns a deep copy of the rule so a playlist never shares it.
+func (r *SmartRule) clone() *SmartRule {
+	if r == nil {
+		return nil
+	}
+	cp := &SmartRule{Match: r.Match, Conditions: make([]RuleCondition, len(r.Conditions))}
+	for i, c := range r.Conditions {
+		if list, ok := c.Value.([]any); ok {
+			c.Value = append([]any(nil), list...)
+		}
+		cp.Conditions[i] = c
+	}
+	return cp
+}
+
+// IsSmart reports whether the playlist's tracks come from a rule.
+func (p *Playlist) IsSmart() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Rule != nil
+}
+
+// SetRule turns the playlist into a smart playlist with the given rule, or
+// back into a regular one when rule is nil; its current tracks are kept
+// either way until the next Refresh.
+func (p *Playlist) SetRule(rule *SmartRule) error {
+	if rule != nil {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Rule = rule.clone()
+	return nil
+}
+
+// Refresh rebuilds a smart playlist from the library. Tracks that still
+// match keep their place, so a shuffled or reordered smart playlist stays
+// that way; tracks that stopped matching are dropped and new matches are
+// appended in library order. It reports whether the tracks changed, and does
+// nothing for a regular playlist.
+func (p *Playlist) Refresh(lib *TrackLibrary, now time.Time) bool {
+	p.mu.RLock()
+	rule := p.Rule
+	p.mu.RUnlock()
+	if rule == nil || lib == nil {
+		return false
+	}
+
+	matched := lib.Filter(func(t *Track) bool { return rule.Matches(t, now) })
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Rule != rule {
+		// The rule was replaced meanwhile; its own refresh will follow.
+		return false
+	}
+
+	want := make(map[string]bool, len(matched))
+	for _, t := range matched {
+		want[t.Checksum] = true
+	}
+	tracks := make([]*Track, 0, len(matched))
+	have := make(map[string]bool, len(matched))
+	for _, t := range p.Tracks {
+		if want[t.Checksum] && !have[t.Checksum] {
+			tracks = append(tracks, t)
+			have[t.Checksum] = true
+		}
+	}
+	for _, t := range matched {
+		if !have[t.Checksum] {
+			tracks = append(tracks, t)
+		}
+	}
+
+	changed := len(tracks) != len(p.Tracks)
+	for i := 0; !changed && i < len(tracks); i++ {
+		changed = tracks[i] != p.Tracks[i]
+	}
+	if !changed {
+		return false
+	}
+	p.Tracks = tracks
+	p.library = lib
+	p.relocateCursorUnsafe()
+	return true
+}
+
+// RefreshSmartPlaylists re-runs the rule of every smart playlist against the
+// library and returns how many of them changed.
+func (mp *MasterPlaylist) RefreshSmartPlaylists() int {
+	now := mp.Now()
+	changed := 0
+	for _, pl := range mp.AllPlaylists() {
+		if pl.Refresh(mp.Library, now) {
+			changed++
+		}
+	}
+	return changed
+}
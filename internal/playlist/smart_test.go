@@ -0,0 +1,171 @@
+package playlist
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+var smartNow = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// smartLibrary returns a library holding tracks, keeping their IDs and
+// AddedAt as given.
+func smartLibrary(tracks ...*Track) *TrackLibrary {
+	lib := NewTrackLibrary()
+	for _, t := range tracks {
+		lib.Import(t)
+	}
+	return lib
+}
+
+func titles(tracks []*Track) []string {
+	names := make([]string, len(tracks))
+	for i, t := range tracks {
+		names[i] = t.Title
+	}
+	return names
+}
+
+func TestSmartRuleMatches(t *testing.T) {
+	track := &Track{
+		Title: "Hare Hare Yukai", Artist: "Aya Hirano", Genre: "Denpa", Format: "mp3",
+		Year: 2006, Duration: 250, AddedAt: smartNow.Add(-48 * time.Hour),
+	}
+	legacy := &Track{Title: "Old", Genre: "Denpa"} // AddedAt never recorded
+
+	tests := []struct {
+		name  string
+		rule  SmartRule
+		track *Track
+		want  bool
+	}{
+		{"all, every condition holds", SmartRule{Conditions: []RuleCondition{
+			{Field: "genre", Op: "eq", Value: "denpa"},
+			{Field: "year", Op: "gte", Value: 2005.0},
+		}}, track, true},
+		{"all, one condition fails", SmartRule{Match: "all", Conditions: []RuleCondition{
+			{Field: "genre", Op: "eq", Value: "denpa"},
+			{Field: "year", Op: "lt", Value: 2000.0},
+		}}, track, false},
+		{"any, one condition holds", SmartRule{Match: "any", Conditions: []RuleCondition{
+			{Field: "genre", Op: "eq", Value: "jazz"},
+			{Field: "duration", Op: "lt", Value: 300.0},
+		}}, track, true},
+		{"any, no condition holds", SmartRule{Match: "any", Conditions: []RuleCondition{
+			{Field: "genre", Op: "eq", Value: "jazz"},
+			{Field: "artist", Op: "contains", Value: "momoi"},
+		}}, track, false},
+		{"text in, case-insensitive", SmartRule{Conditions: []RuleCondition{
+			{Field: "artist", Op: "in", Value: []any{"Halko Momoi", "AYA HIRANO"}},
+		}}, track, true},
+		{"text in, no entry matches", SmartRule{Conditions: []RuleCondition{
+			{Field: "artist", Op: "in", Value: []any{"Halko Momoi"}},
+		}}, track, false},
+		{"number in", SmartRule{Conditions: []RuleCondition{
+			{Field: "year", Op: "in", Value: []any{2005.0, 2006.0}},
+		}}, track, true},
+		{"typed string list", SmartRule{Conditions: []RuleCondition{
+			{Field: "genre", Op: "in", Value: []string{"J-Pop", "Denpa"}},
+		}}, track, true},
+		{"typed number list", SmartRule{Conditions: []RuleCondition{
+			{Field: "year", Op: "in", Value: []int{2004, 2007}},
+		}}, track, false},
+		{"added within the window", SmartRule{Conditions: []RuleCondition{
+			{Field: "added", Op: "within_days", Value: 7.0},
+		}}, track, true},
+		{"added before the window", SmartRule{Conditions: []RuleCondition{
+			{Field: "added", Op: "within_days", Value: 1.0},
+		}}, track, false},
+		{"no added date never counts as recent", SmartRule{Conditions: []RuleCondition{
+			{Field: "added", Op: "within_days", Value: 36500.0},
+		}}, legacy, false},
+		{"corrupt tracks never match", SmartRule{Conditions: []RuleCondition{
+			{Field: "genre", Op: "eq", Value: "denpa"},
+		}}, &Track{Genre: "Denpa", Corrupt: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.rule.Matches(tt.track, smartNow); got != tt.want {
+				t.Fatalf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmartRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule SmartRule
+		ok   bool
+	}{
+		{"no conditions", SmartRule{}, false},
+		{"unknown match", SmartRule{Match: "some", Conditions: []RuleCondition{{Field: "genre", Op: "eq", Value: "x"}}}, false},
+		{"unknown field", SmartRule{Conditions: []RuleCondition{{Field: "mood", Op: "eq", Value: "x"}}}, false},
+		{"operator for another kind", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "gt", Value: "x"}}}, false},
+		{"number for a text field", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "eq", Value: 1.0}}}, false},
+		{"text for a number field", SmartRule{Conditions: []RuleCondition{{Field: "year", Op: "eq", Value: "2006"}}}, false},
+		{"in without a list", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "in", Value: "denpa"}}}, false},
+		{"in with an empty list", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "in", Value: []string{}}}}, false},
+		{"in with a mixed list", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "in", Value: []any{"denpa", 1.0}}}}, false},
+		{"negative days", SmartRule{Conditions: []RuleCondition{{Field: "added", Op: "within_days", Value: -1.0}}}, false},
+		{"int value", SmartRule{Conditions: []RuleCondition{{Field: "year", Op: "eq", Value: 2006}}}, true},
+		{"typed list", SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "in", Value: []string{"denpa"}}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if (err == nil) != tt.ok {
+				t.Fatalf("Validate = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSmartRuleValidateNormalisesTypedList(t *testing.T) {
+	genres := []string{"denpa", "j-pop"}
+	rule := SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "in", Value: genres}}}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	list, ok := rule.Conditions[0].Value.([]any)
+	if !ok || len(list) != 2 || list[0] != "denpa" || list[1] != "j-pop" {
+		t.Fatalf("value = %#v, want []any of the genres", rule.Conditions[0].Value)
+	}
+}
+
+func TestRefreshKeepsOrderOfMatchingTracks(t *testing.T) {
+	a := &Track{ID: 1, Checksum: "a", Title: "a", Genre: "denpa"}
+	b := &Track{ID: 2, Checksum: "b", Title: "b", Genre: "denpa"}
+	c := &Track{ID: 3, Checksum: "c", Title: "c", Genre: "denpa"}
+	d := &Track{ID: 4, Checksum: "d", Title: "d", Genre: "rock"}
+	lib := smartLibrary(a, b, c, d)
+
+	pl := NewPlaylist("Denpa", TagMorning)
+	if err := pl.SetRule(&SmartRule{Conditions: []RuleCondition{{Field: "genre", Op: "eq", Value: "denpa"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !pl.Refresh(lib, smartNow) {
+		t.Fatal("first refresh reported no change")
+	}
+	if got, want := titles(pl.Tracks), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("tracks %v, want %v", got, want)
+	}
+
+	// Reordered by hand, then b stops matching and d starts to.
+	pl.Tracks = []*Track{c, a, b}
+	b.Genre = "rock"
+	d.Genre = "denpa"
+
+	if !pl.Refresh(lib, smartNow) {
+		t.Fatal("refresh reported no change")
+	}
+	if got, want := titles(pl.Tracks), []string{"c", "a", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("tracks %v, want %v", got, want)
+	}
+	if pl.Refresh(lib, smartNow) {
+		t.Fatal("refresh without library changes reported a change")
+	}
+}
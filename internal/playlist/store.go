@@ -6,7 +6,11 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/fileutil"
 )
 
 // ---------------------------------------------------------------------------
@@ -27,17 +31,25 @@ type storeDataV1 struct {
 // Instead of embedding full Track objects it stores an ordered list of
 // checksums that reference entries in the library.
 type storePlaylistV2 struct {
-	ID                   int64    `json:"id"`
-	Name                 string   `json:"name"`
-	Tag                  TimeTag  `json:"tag"`
-	TrackChecksums       []string `json:"trackChecksums"`
-	CurrentTrackChecksum string   `json:"currentTrackChecksum,omitempty"`
+	ID                   int64      `json:"id"`
+	Name                 string     `json:"name"`
+	Tag                  TimeTag    `json:"tag"`
+	TrackChecksums       []string   `json:"trackChecksums"`
+	CurrentTrackChecksum string     `json:"currentTrackChecksum,omitempty"`
+	CrossfadeSeconds     *float64   `json:"crossfadeSeconds,omitempty"`
+	Days                 Weekdays   `json:"days,omitempty"`
+	Rule                 *SmartRule `json:"rule,omitempty"`
 }
 
-// storeDataV2 is the current on-disk format.
+// storeDataV2 is the current on-disk format. Files written before slots
+// were configurable have no Slots and get the default table on load. The
+// weekly schedule is kept as the Days of each playlist; playlists from files
+// written before it have none and keep playing every day.
 type storeDataV2 struct {
 	Version   int                           `json:"version"`
 	Timezone  string                        `json:"timezone,omitempty"`
+	Slots     []TimeSlot                    `json:"slots,omitempty"`
+	Overrides []ScheduleOverride            `json:"overrides,omitempty"`
 	Library   *TrackLibrary                 `json:"library"`
 	Playlists map[string][]*storePlaylistV2 `json:"playlists"`
 }
@@ -85,11 +97,13 @@ func (s *Store) Save(master *MasterPlaylist) error {
 	data := storeDataV2{
 		Version:   2,
 		Timezone:  master.Timezone(),
+		Slots:     append([]TimeSlot(nil), master.slots...),
+		Overrides: append([]ScheduleOverride(nil), master.overrides...),
 		Library:   master.Library,
 		Playlists: make(map[string][]*storePlaylistV2),
 	}
 
-	for _, tag := range ValidTimeTags {
+	for _, tag := range master.tagsUnsafe() {
 		pls := master.getPlaylistsUnsafe(tag)
 		storePls := make([]*storePlaylistV2, 0, len(pls))
 		for _, pl := range pls {
@@ -106,28 +120,8 @@ func (s *Store) Save(master *MasterPlaylist) error {
 		return fmt.Errorf("failed to marshal master playlist: %w", err)
 	}
 
-	// Write to a temporary file in the same directory so the rename is atomic.
-	dir := filepath.Dir(s.path)
-	tmp, err := os.CreateTemp(dir, "playlist-*.json.tmp")
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	tmpName := tmp.Name()
-
-	if _, err := tmp.Write(jsonBytes); err != nil {
-		tmp.Close()
-		os.Remove(tmpName)
-		return fmt.Errorf("failed to write temp file: %w", err)
-	}
-
-	if err := tmp.Close(); err != nil {
-		os.Remove(tmpName)
-		return fmt.Errorf("failed to close temp file: %w", err)
-	}
-
-	if err := os.Rename(tmpName, s.path); err != nil {
-		os.Remove(tmpName)
-		return fmt.Errorf("failed to rename temp file to %q: %w", s.path, err)
+	if err := fileutil.WriteAtomic(s.path, jsonBytes); err != nil {
+		return err
 	}
 
 	slog.Info("Playlist saved to disk", "path", s.path)
@@ -151,6 +145,9 @@ func playlistToStoreV2(pl *Playlist) *storePlaylistV2 {
 		Tag:                  pl.Tag,
 		TrackChecksums:       checksums,
 		CurrentTrackChecksum: pl.CurrentTrackChecksum,
+		CrossfadeSeconds:     pl.CrossfadeSeconds,
+		Days:                 pl.Days,
+		Rule:                 pl.Rule,
 	}
 }
 
@@ -203,16 +200,10 @@ func (s *Store) loadV2(raw []byte) (*MasterPlaylist, error) {
 		}
 	}
 
-	for _, tag := range ValidTimeTags {
-		storePls, ok := data.Playlists[string(tag)]
-		if !ok {
-			continue
-		}
-		for _, sp := range storePls {
-			pl := storeV2ToPlaylist(sp, tag, lib)
-			master.setPlaylistsUnsafe(tag, append(master.getPlaylistsUnsafe(tag), pl))
-		}
-	}
+	restoreSlotsV2(master, data.Slots)
+	restorePlaylistsV2(master, data.Playlists, lib)
+	restoreOverridesV2(master, data.Overrides)
+	master.RefreshSmartPlaylists()
 
 	// Sync the playlist ID counter.
 	syncPlaylistIDCounter(master)
@@ -221,15 +212,60 @@ func (s *Store) loadV2(raw []byte) (*MasterPlaylist, error) {
 		"path", s.path,
 		"timezone", data.Timezone,
 		"library_tracks", lib.Count(),
-		"morning", len(master.Morning),
-		"afternoon", len(master.Afternoon),
-		"evening", len(master.Evening),
-		"night", len(master.Night),
+		"slots", len(master.slots),
+		"overrides", len(master.overrides),
+		"playlists", len(master.AllPlaylists()),
 	)
 
 	return master, nil
 }
 
+// restoreSlotsV2 installs a persisted slot table. An empty or invalid table
+// leaves the defaults in place.
+func restoreSlotsV2(master *MasterPlaylist, slots []TimeSlot) {
+	if len(slots) == 0 {
+		return
+	}
+	if err := ValidateTimeSlots(slots); err != nil {
+		slog.Warn("Ignoring invalid persisted time slots, using defaults", "error", err)
+		return
+	}
+	master.slots = slots
+}
+
+// restorePlaylistsV2 rebuilds the playlists of every tag in the file. Tags
+// are visited in sorted order so playlist order is stable across loads.
+func restorePlaylistsV2(master *MasterPlaylist, stored map[string][]*storePlaylistV2, lib *TrackLibrary) {
+	tags := make([]string, 0, len(stored))
+	for tag := range stored {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, name := range tags {
+		tag := TimeTag(name)
+		for _, sp := range stored[name] {
+			pl := storeV2ToPlaylist(sp, tag, lib)
+			master.setPlaylistsUnsafe(tag, append(master.getPlaylistsUnsafe(tag), pl))
+		}
+		if len(master.getPlaylistsUnsafe(tag)) > 0 && !master.hasSlotUnsafe(tag) {
+			slog.Warn("Playlists assigned to an unknown time slot", "tag", tag)
+		}
+	}
+}
+
+// restoreOverridesV2 installs the persisted schedule overrides, skipping
+// any that no longer validate.
+func restoreOverridesV2(master *MasterPlaylist, overrides []ScheduleOverride) {
+	for _, o := range overrides {
+		if err := o.Validate(); err != nil {
+			slog.Warn("Ignoring invalid persisted schedule override", "id", o.ID, "name", o.Name, "error", err)
+			continue
+		}
+		master.overrides = append(master.overrides, o)
+	}
+}
+
 // storeV2ToPlaylist converts a v2 on-disk playlist back into a runtime
 // Playlist, resolving track checksums from the library.
 func storeV2ToPlaylist(sp *storePlaylistV2, tag TimeTag, lib *TrackLibrary) *Playlist {
@@ -241,8 +277,17 @@ func storeV2ToPlaylist(sp *storePlaylistV2, tag TimeTag, lib *TrackLibrary) *Pla
 		Tag:                  tag,
 		Tracks:               tracks,
 		CurrentTrackChecksum: sp.CurrentTrackChecksum,
+		CrossfadeSeconds:     sp.CrossfadeSeconds,
+		Days:                 sp.Days,
 		library:              lib,
 	}
+	if sp.Rule != nil {
+		if err := sp.Rule.Validate(); err != nil {
+			slog.Warn("Ignoring invalid smart playlist rule", "playlist_id", sp.ID, "name", sp.Name, "error", err)
+		} else {
+			pl.Rule = sp.Rule
+		}
+	}
 
 	// Restore the current index from the checksum if possible.
 	if sp.CurrentTrackChecksum != "" {
@@ -291,20 +336,17 @@ func (s *Store) loadV1(raw []byte) (*MasterPlaylist, error) {
 	restorePlaylistsV1(data.Evening, TagEvening, lib)
 	restorePlaylistsV1(data.Night, TagNight, lib)
 
-	master.Morning = nonNilPlaylists(data.Morning)
-	master.Afternoon = nonNilPlaylists(data.Afternoon)
-	master.Evening = nonNilPlaylists(data.Evening)
-	master.Night = nonNilPlaylists(data.Night)
+	master.setPlaylistsUnsafe(TagMorning, data.Morning)
+	master.setPlaylistsUnsafe(TagAfternoon, data.Afternoon)
+	master.setPlaylistsUnsafe(TagEvening, data.Evening)
+	master.setPlaylistsUnsafe(TagNight, data.Night)
 
 	// Sync the playlist ID counter.
 	syncPlaylistIDCounter(master)
 
 	slog.Info("Migration complete",
 		"library_tracks", lib.Count(),
-		"morning", len(master.Morning),
-		"afternoon", len(master.Afternoon),
-		"evening", len(master.Evening),
-		"night", len(master.Night),
+		"playlists", len(master.AllPlaylists()),
 	)
 
 	return master, nil
@@ -325,20 +367,12 @@ func restorePlaylistsV1(playlists []*Playlist, tag TimeTag, lib *TrackLibrary) {
 	}
 }
 
-// nonNilPlaylists returns the slice as-is if non-nil, or an empty slice.
-func nonNilPlaylists(pls []*Playlist) []*Playlist {
-	if pls == nil {
-		return make([]*Playlist, 0)
-	}
-	return pls
-}
-
 // syncPlaylistIDCounter scans all playlists in the master and updates the
 // global playlist ID counter so that new playlists get unique IDs.
 func syncPlaylistIDCounter(master *MasterPlaylist) {
 	var maxPlaylist int64
 
-	for _, tag := range ValidTimeTags {
+	for _, tag := range master.tagsUnsafe() {
 		for _, pl := range master.getPlaylistsUnsafe(tag) {
 			if pl.ID > maxPlaylist {
 				maxPlaylist = pl.ID
@@ -412,6 +446,12 @@ func ImportPlaylistFromBytes(data []byte) (*Playlist, error) {
 		return nil, fmt.Errorf("failed to parse playlist data: %w", err)
 	}
 
+	if pl.Rule != nil {
+		if err := pl.Rule.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Assign a fresh ID so it doesn't clash with existing playlists.
 	pl.ID = nextPlaylistID()
 
@@ -441,7 +481,8 @@ func ImportPlaylistFromBytes(data []byte) (*Playlist, error) {
 
 // ImportPlaylistIntoLibrary imports a playlist and integrates its tracks into
 // the provided library. Tracks that already exist in the library (by checksum)
-// are resolved to the canonical library pointer; new tracks are added.
+// are resolved to the canonical library pointer; new tracks are added. A
+// smart playlist is then rebuilt from its rule against the library.
 func ImportPlaylistIntoLibrary(data []byte, lib *TrackLibrary) (*Playlist, error) {
 	pl, err := ImportPlaylistFromBytes(data)
 	if err != nil {
@@ -458,6 +499,7 @@ func ImportPlaylistIntoLibrary(data []byte, lib *TrackLibrary) (*Playlist, error
 		pl.Tracks[i] = canonical
 	}
 	pl.library = lib
+	pl.Refresh(lib, time.Now())
 
 	return pl, nil
 }
@@ -470,11 +512,13 @@ func ExportMasterPlaylist(master *MasterPlaylist) ([]byte, error) {
 	data := storeDataV2{
 		Version:   2,
 		Timezone:  master.Timezone(),
+		Slots:     append([]TimeSlot(nil), master.slots...),
+		Overrides: append([]ScheduleOverride(nil), master.overrides...),
 		Library:   master.Library,
 		Playlists: make(map[string][]*storePlaylistV2),
 	}
 
-	for _, tag := range ValidTimeTags {
+	for _, tag := range master.tagsUnsafe() {
 		pls := master.getPlaylistsUnsafe(tag)
 		storePls := make([]*storePlaylistV2, 0, len(pls))
 		for _, pl := range pls {
@@ -513,17 +557,10 @@ func ImportMasterPlaylist(data []byte) (*MasterPlaylist, error) {
 		}
 
 		master := NewMasterPlaylistWithLibrary(lib)
-
-		for _, tag := range ValidTimeTags {
-			storePls, ok := sd.Playlists[string(tag)]
-			if !ok {
-				continue
-			}
-			for _, sp := range storePls {
-				pl := storeV2ToPlaylist(sp, tag, lib)
-				master.setPlaylistsUnsafe(tag, append(master.getPlaylistsUnsafe(tag), pl))
-			}
-		}
+		restoreSlotsV2(master, sd.Slots)
+		restorePlaylistsV2(master, sd.Playlists, lib)
+		restoreOverridesV2(master, sd.Overrides)
+		master.RefreshSmartPlaylists()
 
 		syncPlaylistIDCounter(master)
 		return master, nil
@@ -555,10 +592,10 @@ func ImportMasterPlaylist(data []byte) (*MasterPlaylist, error) {
 	restorePlaylistsV1(sd.Night, TagNight, lib)
 
 	master := NewMasterPlaylistWithLibrary(lib)
-	master.Morning = nonNilPlaylists(sd.Morning)
-	master.Afternoon = nonNilPlaylists(sd.Afternoon)
-	master.Evening = nonNilPlaylists(sd.Evening)
-	master.Night = nonNilPlaylists(sd.Night)
+	master.setPlaylistsUnsafe(TagMorning, sd.Morning)
+	master.setPlaylistsUnsafe(TagAfternoon, sd.Afternoon)
+	master.setPlaylistsUnsafe(TagEvening, sd.Evening)
+	master.setPlaylistsUnsafe(TagNight, sd.Night)
 
 	syncPlaylistIDCounter(master)
 	return master, nil
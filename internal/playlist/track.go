@@ -1,6 +1,7 @@
 package playlist
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
 	"io"
@@ -8,7 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
 	"github.com/dhowden/tag"
 )
 
@@ -25,6 +28,28 @@ type Track struct {
 	FilePath string `json:"filePath"`
 	Format   string `json:"format"`
 	Checksum string `json:"checksum"`
+
+	// EBU R128 analysis, nil until the track has been measured.
+	Loudness *float64 `json:"loudness,omitempty"` // integrated loudness, LUFS
+	TruePeak *float64 `json:"truePeak,omitempty"` // maximum true peak, dBTP
+
+	// Audio stream properties from ffprobe, zero until the file is probed.
+	Codec      string `json:"codec,omitempty"`
+	Bitrate    int    `json:"bitrate,omitempty"`    // bits per second
+	SampleRate int    `json:"sampleRate,omitempty"` // Hz
+	Channels   int    `json:"channels,omitempty"`
+
+	// Corrupt marks a file ffprobe could not read; ProbeError says why.
+	Corrupt    bool   `json:"corrupt,omitempty"`
+	ProbeError string `json:"probeError,omitempty"`
+
+	// AddedAt is when the track joined the library; zero for tracks added
+	// before the date was recorded.
+	AddedAt time.Time `json:"addedAt,omitzero"`
+
+	// Jingle marks a station ID from the jingle pool. Jingles never live in
+	// the library, so the flag is not persisted.
+	Jingle bool `json:"-"`
 }
 
 // SupportedFormats lists the audio file extensions that are recognized.
@@ -76,6 +101,32 @@ func NewTrackFromFile(path string) (*Track, error) {
 	return track, nil
 }
 
+// Prober reads the duration and stream properties of an audio file.
+// ffmpeg.Encoder implements it with ffprobe.
+type Prober interface {
+	Probe(ctx context.Context, inputFile string) (ffmpeg.AudioInfo, error)
+}
+
+// ProbeTrack fills in the track's duration and stream properties using
+// prober. If the file cannot be probed the track is marked corrupt and the
+// error is returned.
+func ProbeTrack(ctx context.Context, t *Track, prober Prober) error {
+	info, err := prober.Probe(ctx, t.FilePath)
+	if err != nil {
+		t.Corrupt = true
+		t.ProbeError = err.Error()
+		return err
+	}
+	t.Corrupt = false
+	t.ProbeError = ""
+	t.Duration = int(info.Duration.Round(time.Second) / time.Second)
+	t.Codec = info.Codec
+	t.Bitrate = info.Bitrate
+	t.SampleRate = info.SampleRate
+	t.Channels = info.Channels
+	return nil
+}
+
 // NewTrackFromExisting creates a Track with all fields pre-populated. This is
 // used when loading from persisted data where metadata is already known.
 func NewTrackFromExisting(id int64, title, artist, album, genre string, year, trackNum, duration int, filePath, format, checksum string) *Track {
@@ -0,0 +1,194 @@
+package playlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Weekdays is a set of days of the week, one bit per time.Weekday. The zero
+// value means every day, so playlists saved before the weekly schedule
+// existed keep playing daily.
+type Weekdays uint8
+
+const (
+	Weekend     Weekdays = 1<<time.Saturday | 1<<time.Sunday
+	WorkingDays Weekdays = 1<<time.Monday | 1<<time.Tuesday | 1<<time.Wednesday | 1<<time.Thursday | 1<<time.Friday
+	EveryDay    Weekdays = Weekend | WorkingDays
+)
+
+// weekOrder lists the days Monday first, the way the schedule is shown.
+var weekOrder = []time.Weekday{
+	time.Monday, time.Tuesday, time.Wednesday, time.Thursday,
+	time.Friday, time.Saturday, time.Sunday,
+}
+
+// dayNames maps the accepted spellings of a day, or a group of days, to its
+// set. "weekdays" and "weekend" are the shortcuts for the working week and
+// Saturday plus Sunday.
+var dayNames = map[string]Weekdays{
+	"weekdays": WorkingDays,
+	"weekend":  Weekend,
+	"daily":    EveryDay,
+}
+
+func init() {
+	for _, d := range weekOrder {
+		full := strings.ToLower(d.String())
+		dayNames[full] = 1 << d
+		dayNames[full[:3]] = 1 << d
+	}
+}
+
+// ParseWeekdays parses day names such as "mon", "saturday", "weekdays" or
+// "weekend" into a set. An empty list means every day.
+func ParseWeekdays(names []string) (Weekdays, error) {
+	var w Weekdays
+	for _, n := range names {
+		d, ok := dayNames[strings.ToLower(strings.TrimSpace(n))]
+		if !ok {
+			return 0, fmt.Errorf("invalid day %q: use mon..sun, weekdays, weekend or daily", n)
+		}
+		w |= d
+	}
+	if w == EveryDay {
+		return 0, nil
+	}
+	return w, nil
+}
+
+// Has reports whether d is in the set.
+func (w Weekdays) Has(d time.Weekday) bool {
+	return w == 0 || w&(1<<d) != 0
+}
+
+// Names returns the short names of the days in the set, Monday first.
+func (w Weekdays) Names() []string {
+	names := make([]string, 0, len(weekOrder))
+	for _, d := range weekOrder {
+		if w.Has(d) {
+			names = append(names, strings.ToLower(d.String()[:3]))
+		}
+	}
+	return names
+}
+
+func (w Weekdays) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Names())
+}
+
+func (w *Weekdays) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("invalid days: expected a list of day names")
+	}
+	v, err := ParseWeekdays(names)
+	if err != nil {
+		return err
+	}
+	*w = v
+	return nil
+}
+
+// scheduleDay returns the day of the week whose schedule applies at now for
+// the given slot. A slot that runs past midnight belongs to the day it
+// started on, so Friday's night show keeps playing Friday's playlists after
+// midnight.
+func scheduleDay(slot TimeSlot, now time.Time) time.Weekday {
+	if ClockTimeOf(now) < slot.Start {
+		return now.AddDate(0, 0, -1).Weekday()
+	}
+	return now.Weekday()
+}
+
+// slotAndDayAt returns the slot covering now and the day of the week its
+// schedule is taken from. The slot is empty if there is a gap in the table.
+func slotAndDayAt(slots []TimeSlot, now time.Time) (TimeTag, time.Weekday) {
+	tag := slotAt(slots, ClockTimeOf(now))
+	for _, s := range slots {
+		if s.Name == tag {
+			return tag, scheduleDay(s, now)
+		}
+	}
+	return "", now.Weekday()
+}
+
+// scheduledUnsafe returns the playlists of tag that play on day, in
+// assignment order, taking the active schedule override into account. The
+// caller must hold at least a read lock.
+func (mp *MasterPlaylist) scheduledUnsafe(tag TimeTag, day time.Weekday) []*Playlist {
+	if pls := mp.overridePlaylistsUnsafe(tag, day); len(pls) > 0 {
+		return pls
+	}
+	return mp.weeklyUnsafe(tag, day)
+}
+
+// weeklyUnsafe returns the playlists of tag that the normal weekly schedule
+// plays on day. The caller must hold at least a read lock.
+func (mp *MasterPlaylist) weeklyUnsafe(tag TimeTag, day time.Weekday) []*Playlist {
+	all := mp.getPlaylistsUnsafe(tag)
+	pls := make([]*Playlist, 0, len(all))
+	for _, pl := range all {
+		if pl.Days.Has(day) && !mp.seasonalUnsafe(pl.ID) {
+			pls = append(pls, pl)
+		}
+	}
+	return pls
+}
+
+// activePlaylistsUnsafe returns the playlists scheduled for the active slot
+// on the active day. The caller must hold at least a read lock.
+func (mp *MasterPlaylist) activePlaylistsUnsafe() []*Playlist {
+	return mp.scheduledUnsafe(mp.activeTag, mp.activeDay)
+}
+
+// ActiveDay returns the day of the week the active slot's schedule is taken
+// from.
+func (mp *MasterPlaylist) ActiveDay() time.Weekday {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.activeDay
+}
+
+// SetPlaylistDays changes the days of the week a playlist plays on within
+// its slot. Zero means every day.
+func (mp *MasterPlaylist) SetPlaylistDays(playlistID int64, days Weekdays) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	for _, tag := range mp.tagsUnsafe() {
+		for _, pl := range mp.getPlaylistsUnsafe(tag) {
+			if pl.ID == playlistID {
+				pl.Days = days
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("playlist %d not found", playlistID)
+}
+
+// WeekSchedule is the weekly grid for one day: the playlists each slot
+// plays on that day.
+type WeekSchedule struct {
+	Day   time.Weekday
+	Slots map[TimeTag][]*Playlist
+}
+
+// Week returns the normal weekly grid, Monday first, without schedule
+// overrides. Every slot is listed for every day, with an empty list where
+// nothing is scheduled.
+func (mp *MasterPlaylist) Week() []WeekSchedule {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	week := make([]WeekSchedule, 0, len(weekOrder))
+	for _, d := range weekOrder {
+		day := WeekSchedule{Day: d, Slots: make(map[TimeTag][]*Playlist, len(mp.slots))}
+		for _, tag := range mp.tagsUnsafe() {
+			day.Slots[tag] = mp.weeklyUnsafe(tag, d)
+		}
+		week = append(week, day)
+	}
+	return week
+}
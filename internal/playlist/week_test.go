@@ -0,0 +1,91 @@
+package playlist
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWeekdays(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  Weekdays
+		err   bool
+	}{
+		{nil, 0, false},
+		{[]string{"daily"}, 0, false},
+		{[]string{"weekdays"}, WorkingDays, false},
+		{[]string{"weekend"}, Weekend, false},
+		{[]string{"Mon", " sat "}, 1<<time.Monday | 1<<time.Saturday, false},
+		{[]string{"friday", "fri"}, 1 << time.Friday, false},
+		{[]string{"SUNDAY"}, 1 << time.Sunday, false},
+		// The whole week is stored as the zero value, like an empty list.
+		{[]string{"weekdays", "weekend"}, 0, false},
+		{[]string{"weekdays", "sat", "sun"}, 0, false},
+		{[]string{"mon", "funday"}, 0, true},
+		{[]string{""}, 0, true},
+		{[]string{"mo"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseWeekdays(tt.names)
+		if (err != nil) != tt.err {
+			t.Fatalf("ParseWeekdays(%q) error = %v, want error %v", tt.names, err, tt.err)
+		}
+		if got != tt.want {
+			t.Fatalf("ParseWeekdays(%q) = %v, want %v", tt.names, got.Names(), tt.want.Names())
+		}
+	}
+}
+
+func TestScheduleDay(t *testing.T) {
+	overnight := TimeSlot{Name: "late", Start: 22 * 60, End: 2 * 60}
+	morning := TimeSlot{Name: "morning", Start: 6 * 60, End: 12 * 60}
+	allDay := TimeSlot{Name: "allday", Start: 0, End: 0}
+
+	tests := []struct {
+		name string
+		slot TimeSlot
+		now  time.Time
+		want time.Weekday
+	}{
+		{"overnight before midnight", overnight, at(6, 22, 0), time.Friday},
+		{"overnight at 01:00 on Saturday", overnight, at(7, 1, 0), time.Friday},
+		{"overnight last minute", overnight, at(7, 1, 59), time.Friday},
+		{"overnight into Monday", overnight, at(9, 0, 30), time.Sunday},
+		{"daytime slot", morning, at(7, 9, 0), time.Saturday},
+		{"daytime slot at its start", morning, at(8, 6, 0), time.Sunday},
+		{"all-day slot at midnight", allDay, at(8, 0, 0), time.Sunday},
+		{"all-day slot before midnight", allDay, at(7, 23, 59), time.Saturday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scheduleDay(tt.slot, tt.now); got != tt.want {
+				t.Fatalf("scheduleDay at %s = %s, want %s", tt.now.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlotAndDayAt(t *testing.T) {
+	slots := []TimeSlot{
+		{Name: "day", Start: 8 * 60, End: 20 * 60},
+		{Name: "late", Start: 22 * 60, End: 2 * 60},
+	}
+	tests := []struct {
+		now time.Time
+		tag TimeTag
+		day time.Weekday
+	}{
+		{at(6, 12, 0), "day", time.Friday},
+		{at(6, 23, 0), "late", time.Friday},
+		{at(7, 1, 0), "late", time.Friday},
+		// Nothing runs between 02:00 and 08:00: no slot, today's date.
+		{at(7, 3, 0), "", time.Saturday},
+		{at(6, 21, 0), "", time.Friday},
+	}
+	for _, tt := range tests {
+		tag, day := slotAndDayAt(slots, tt.now)
+		if tag != tt.tag || day != tt.day {
+			t.Fatalf("slotAndDayAt(%s) = %q %s, want %q %s", tt.now.Format("Mon 15:04"), tag, day, tt.tag, tt.day)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package radio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// pcmSampleBytes is the size of one s16le sample for a single channel.
+const pcmSampleBytes = 2
+
+// ---------------------------------------------------------------------------
+// pacedWriter forwards PCM to the live encoder no faster than real time, so
+// the encoded stream reaches listeners at playback speed. It replaces the
+// per-file "-re" flag used when every track had its own ffmpeg process.
+// ---------------------------------------------------------------------------
+
+// paceLead is how far ahead of real time the writer may run. A little slack
+// keeps the encoder fed across track boundaries while the next decoder starts.
+const paceLead = 250 * time.Millisecond
+
+// paceMaxLag is how far behind real time the writer may fall before it
+// re-anchors its clock instead of bursting to catch up.
+const paceMaxLag = 2 * time.Second
+
+type pacedWriter struct {
+	w           io.Writer
+	bytesPerSec int
+	chunk       int
+	start       time.Time
+	written     int64
+}
+
+func newPacedWriter(w io.Writer, bytesPerSec int) *pacedWriter {
+	return &pacedWriter{
+		w:           w,
+		bytesPerSec: bytesPerSec,
+		chunk:       bytesPerSec / 20, // 50 ms slices
+	}
+}
+
+func (pw *pacedWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > pw.chunk {
+			n = pw.chunk
+		}
+		pw.wait()
+		if _, err := pw.w.Write(p[:n]); err != nil {
+			return total, err
+		}
+		pw.written += int64(n)
+		total += n
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// wait sleeps until the next slice is due.
+func (pw *pacedWriter) wait() {
+	now := time.Now()
+	if pw.start.IsZero() {
+		pw.start = now
+	}
+	played := time.Duration(float64(pw.written) / float64(pw.bytesPerSec) * float64(time.Second))
+	ahead := pw.start.Add(played).Sub(now) - paceLead
+	if ahead > 0 {
+		time.Sleep(ahead)
+		return
+	}
+	if -ahead > paceMaxLag {
+		// The source stalled (slow decoder start, empty playlist, …).
+		// Resume at real time rather than flooding the encoder.
+		pw.start = now.Add(-played)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// crossfader joins the PCM of consecutive tracks. It holds back the last
+// holdBytes of the current track; when the next track starts, that outro is
+// mixed with the intro using a linear fade. With a zero crossfade the tracks
+// are simply concatenated, which keeps playback gapless.
+// ---------------------------------------------------------------------------
+
+type crossfader struct {
+	out       io.Writer
+	frameSize int // bytes per PCM frame (all channels)
+	cutBytes  int // length of the fade-out applied when a track is cut
+
+	fadeBytes int    // crossfade length requested for the current track
+	hold      []byte // current-track bytes not yet written to out
+	tail      []byte // previous track's outro being mixed into this intro
+
+	trackPos   int // bytes of the current track received so far
+	flushedPos int // bytes of the current track written to out
+	mixedPos   int // bytes of the current track mixed with tail
+
+	// fadeIn is how much of the current track is still to be faded back
+	// in after FadeOut.
+	fadeIn int
+
+	// onAir mirrors flushedPos for readers on other goroutines, such as
+	// the now-playing progress.
+	onAir atomic.Int64
+}
+
+func newCrossfader(out io.Writer, frameSize, cutBytes int) *crossfader {
+	return &crossfader{
+		out:       out,
+		frameSize: frameSize,
+		cutBytes:  cutBytes - cutBytes%frameSize,
+	}
+}
+
+// StartTrack prepares for a new track whose outro should overlap the next
+// track by fadeBytes.
+func (cf *crossfader) StartTrack(fadeBytes int) {
+	cf.fadeBytes = fadeBytes - fadeBytes%cf.frameSize
+	cf.hold = cf.hold[:0]
+	cf.trackPos = 0
+	cf.flushedPos = 0
+	cf.mixedPos = 0
+	cf.fadeIn = 0
+	cf.onAir.Store(0)
+}
+
+// holdBytes is how much of the current track is kept back. Even without a
+// crossfade a short window is kept so that a skip can fade out cleanly.
+func (cf *crossfader) holdBytes() int {
+	if cf.fadeBytes > cf.cutBytes {
+		return cf.fadeBytes
+	}
+	return cf.cutBytes
+}
+
+// Write receives decoded PCM for the current track.
+func (cf *crossfader) Write(p []byte) (int, error) {
+	cf.hold = append(cf.hold, p...)
+	cf.trackPos += len(p)
+	cf.mixTail()
+	if err := cf.flush(len(cf.hold) - cf.holdBytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// EndTrack is called when the current track finished naturally. Its held
+// outro becomes the tail for the next track.
+func (cf *crossfader) EndTrack() error {
+	cf.dropPartialFrame()
+
+	// The intro was shorter than the outro being faded: play the remainder
+	// of the outro on its own.
+	if cf.mixedPos < len(cf.tail) {
+		rest := cf.tail[cf.mixedPos:]
+		total := len(cf.tail) / cf.frameSize
+		start := cf.mixedPos / cf.frameSize
+		faded := make([]byte, len(rest))
+		copy(faded, rest)
+		applyGain(faded, cf.frameSize, func(frame int) float64 {
+			return 1 - float64(start+frame)/float64(total)
+		})
+		cf.hold = append(cf.hold, faded...)
+		cf.mixedPos = len(cf.tail)
+	}
+
+	if cf.fadeBytes == 0 {
+		cf.tail = cf.tail[:0]
+		return cf.flush(len(cf.hold))
+	}
+
+	if err := cf.flush(len(cf.hold) - cf.fadeBytes); err != nil {
+		return err
+	}
+	cf.tail = append(cf.tail[:0], cf.hold...)
+	cf.hold = cf.hold[:0]
+	return nil
+}
+
+// Cut is called when the current track was skipped. The held audio is faded
+// out quickly and the rest is discarded, so the next track starts without a
+// crossfade.
+func (cf *crossfader) Cut() error {
+	cf.dropPartialFrame()
+
+	n := len(cf.hold)
+	if n > cf.cutBytes {
+		n = cf.cutBytes
+	}
+	if cf.fadeIn > 0 {
+		// Faded out for a pause: the track is already silent.
+		n = 0
+		cf.fadeIn = 0
+	}
+	fade := cf.hold[:n]
+	frames := n / cf.frameSize
+	applyGain(fade, cf.frameSize, func(frame int) float64 {
+		return 1 - float64(frame+1)/float64(frames)
+	})
+
+	cf.hold = cf.hold[:0]
+	cf.tail = cf.tail[:0]
+	cf.mixedPos = 0
+	if n == 0 {
+		return nil
+	}
+	_, err := cf.out.Write(fade)
+	cf.flushedPos += n
+	cf.onAir.Store(int64(cf.flushedPos))
+	return err
+}
+
+// FadeOut airs the start of the held audio faded to silence, so a pause
+// does not stop the track mid-sample. The audio after it fades back in as
+// it is flushed once the track continues.
+func (cf *crossfader) FadeOut() error {
+	n := min(len(cf.hold), cf.cutBytes)
+	if cf.mixedPos < len(cf.tail) {
+		n = min(n, cf.mixedPos-cf.flushedPos)
+	}
+	n -= n % cf.frameSize
+	if n > 0 {
+		frames := n / cf.frameSize
+		applyGain(cf.hold[:n], cf.frameSize, func(frame int) float64 {
+			return 1 - float64(frame+1)/float64(frames)
+		})
+		if err := cf.flush(n); err != nil {
+			return err
+		}
+	}
+	cf.fadeIn = cf.cutBytes
+	return nil
+}
+
+// outroBytes is the length of the ended track's outro held back to be
+// mixed into the next track.
+func (cf *crossfader) outroBytes() int {
+	return len(cf.tail)
+}
+
+// mixTail overlays the previous outro onto the received part of the intro.
+func (cf *crossfader) mixTail() {
+	if cf.mixedPos >= len(cf.tail) {
+		return
+	}
+	end := cf.trackPos
+	if end > len(cf.tail) {
+		end = len(cf.tail)
+	}
+	end -= end % cf.frameSize
+
+	total := len(cf.tail) / cf.frameSize
+	for pos := cf.mixedPos; pos < end; pos += pcmSampleBytes {
+		g := float64(pos/cf.frameSize) / float64(total)
+		i := pos - cf.flushedPos
+		a := float64(int16(binary.LittleEndian.Uint16(cf.tail[pos:])))
+		b := float64(int16(binary.LittleEndian.Uint16(cf.hold[i:])))
+		binary.LittleEndian.PutUint16(cf.hold[i:], uint16(clampSample(a*(1-g)+b*g)))
+	}
+	cf.mixedPos = end
+}
+
+// flush writes up to n held bytes to out, never passing audio that still
+// has to be mixed with the previous outro.
+func (cf *crossfader) flush(n int) error {
+	if cf.mixedPos < len(cf.tail) && n > cf.mixedPos-cf.flushedPos {
+		n = cf.mixedPos - cf.flushedPos
+	}
+	n -= n % cf.frameSize
+	if n <= 0 {
+		return nil
+	}
+	if cf.fadeIn > 0 {
+		total := cf.cutBytes / cf.frameSize
+		done := (cf.cutBytes - cf.fadeIn) / cf.frameSize
+		m := min(n, cf.fadeIn)
+		applyGain(cf.hold[:m], cf.frameSize, func(frame int) float64 {
+			return float64(done+frame+1) / float64(total)
+		})
+		cf.fadeIn -= m
+	}
+	_, err := cf.out.Write(cf.hold[:n])
+	cf.hold = append(cf.hold[:0], cf.hold[n:]...)
+	cf.flushedPos += n
+	cf.onAir.Store(int64(cf.flushedPos))
+	return err
+}
+
+// dropPartialFrame discards a trailing incomplete frame left by a decoder
+// that stopped mid-write.
+func (cf *crossfader) dropPartialFrame() {
+	if extra := len(cf.hold) % cf.frameSize; extra != 0 {
+		cf.hold = cf.hold[:len(cf.hold)-extra]
+	}
+}
+
+// applyGain scales every sample in pcm by gain(frameIndex).
+func applyGain(pcm []byte, frameSize int, gain func(frame int) float64) {
+	for pos := 0; pos+pcmSampleBytes <= len(pcm); pos += pcmSampleBytes {
+		g := gain(pos / frameSize)
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[pos:])))
+		binary.LittleEndian.PutUint16(pcm[pos:], uint16(clampSample(v*g)))
+	}
+}
+
+func clampSample(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
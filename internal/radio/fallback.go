@@ -0,0 +1,183 @@
+package radio
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// FallbackKind selects what the broadcaster plays when nothing else can be
+// played.
+type FallbackKind string
+
+const (
+	FallbackSilence FallbackKind = "silence" // digital silence
+	FallbackTone    FallbackKind = "tone"    // quiet 440 Hz sine
+	FallbackFile    FallbackKind = "file"    // a looped station-ID file
+)
+
+// FallbackSource describes the dead-air fallback.
+type FallbackSource struct {
+	Kind FallbackKind
+	File string // only for FallbackFile
+}
+
+// ParseFallbackSource parses a FALLBACK_SOURCE value: "silence", "tone", or
+// the path of an audio file to loop.
+func ParseFallbackSource(spec string) FallbackSource {
+	switch strings.ToLower(strings.TrimSpace(spec)) {
+	case "", string(FallbackSilence):
+		return FallbackSource{Kind: FallbackSilence}
+	case string(FallbackTone):
+		return FallbackSource{Kind: FallbackTone}
+	default:
+		return FallbackSource{Kind: FallbackFile, File: strings.TrimSpace(spec)}
+	}
+}
+
+// fallbackRound is how much generated audio is played before the playlist
+// is tried again.
+const fallbackRound = 5 * time.Second
+
+// maxConsecutiveFailures is how many tracks in a row may fail to decode
+// before the fallback takes over.
+const maxConsecutiveFailures = 3
+
+// toneFrequency and toneAmplitude define the generated fallback tone.
+const (
+	toneFrequency = 440.0
+	toneAmplitude = 0.1
+)
+
+// SetFallback configures what is played when no track can be played.
+func (b *Broadcaster) SetFallback(src FallbackSource) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fallback = src
+}
+
+// FallbackReason explains why the fallback source is on air, or returns an
+// empty string during normal playout.
+func (b *Broadcaster) FallbackReason() string {
+	v, _ := b.fallbackReason.Load().(string)
+	return v
+}
+
+// endFallback records that regular programme audio is flowing again.
+func (b *Broadcaster) endFallback() {
+	if prev := b.FallbackReason(); prev != "" {
+		b.fallbackReason.Store("")
+		slog.Info("Fallback ended, regular playout resumed", "reason", prev)
+	}
+}
+
+// playFallback keeps encoded audio flowing for one round when the playlist
+// cannot supply a playable track, so listeners are not left with a stalled
+// connection. It returns early if ctx is cancelled or a skip is requested.
+func (b *Broadcaster) playFallback(ctx context.Context, mixer *crossfader, reason string) error {
+	if b.FallbackReason() != reason {
+		b.fallbackReason.Store(reason)
+		slog.Warn("Playing fallback source", "reason", reason)
+	}
+	b.liftPause("fallback source on air")
+	b.currentTrack.Store("")
+	b.currentInfo.Store((*playlist.Track)(nil))
+	b.clearProgress()
+
+	b.mu.RLock()
+	src := b.fallback
+	b.mu.RUnlock()
+
+	mixer.StartTrack(0)
+
+	if src.Kind == FallbackFile {
+		fbCtx, cancel := context.WithCancel(ctx)
+		stop := b.watchSkip(fbCtx, cancel)
+		err := b.encoder.Decode(fbCtx, src.File, ffmpeg.DecodeOptions{}, mixer)
+		cancel()
+		skipped := <-stop
+		if ctx.Err() != nil {
+			return nil
+		}
+		if skipped {
+			return mixer.Cut()
+		}
+		if err == nil {
+			return mixer.EndTrack()
+		}
+		slog.Error("Fallback file failed, using silence", "file", src.File, "error", err)
+		src.Kind = FallbackSilence
+	}
+
+	gen := newSignalGenerator(src.Kind, b.encoder.SampleRateHz(), b.encoder.ChannelCount())
+	chunk := make([]byte, b.encoder.BytesPerSecond()/20) // 50 ms
+	chunk = chunk[:len(chunk)-len(chunk)%b.encoder.FrameBytes()]
+	for played := time.Duration(0); played < fallbackRound; played += 50 * time.Millisecond {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-b.skipCh:
+			return mixer.Cut()
+		default:
+		}
+		gen.fill(chunk)
+		if _, err := mixer.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return mixer.EndTrack()
+}
+
+// watchSkip cancels the current source when Skip is called. The returned
+// channel yields whether a skip happened once ctx is done.
+func (b *Broadcaster) watchSkip(ctx context.Context, cancel context.CancelFunc) <-chan bool {
+	done := make(chan bool, 1)
+	go func() {
+		select {
+		case <-b.skipCh:
+			cancel()
+			done <- true
+		case <-ctx.Done():
+			done <- false
+		}
+	}()
+	return done
+}
+
+// signalGenerator produces s16le PCM for generated fallback sources.
+type signalGenerator struct {
+	kind       FallbackKind
+	sampleRate int
+	channels   int
+	phase      float64
+}
+
+func newSignalGenerator(kind FallbackKind, sampleRate, channels int) *signalGenerator {
+	return &signalGenerator{kind: kind, sampleRate: sampleRate, channels: channels}
+}
+
+// fill overwrites buf with the next stretch of the signal.
+func (g *signalGenerator) fill(buf []byte) {
+	if g.kind != FallbackTone {
+		clear(buf)
+		return
+	}
+	step := 2 * math.Pi * toneFrequency / float64(g.sampleRate)
+	frameSize := g.channels * pcmSampleBytes
+	for pos := 0; pos+frameSize <= len(buf); pos += frameSize {
+		v := uint16(clampSample(math.Sin(g.phase) * toneAmplitude * math.MaxInt16))
+		for ch := 0; ch < g.channels; ch++ {
+			binary.LittleEndian.PutUint16(buf[pos+ch*pcmSampleBytes:], v)
+		}
+		g.phase += step
+		if g.phase > 2*math.Pi {
+			g.phase -= 2 * math.Pi
+		}
+	}
+}
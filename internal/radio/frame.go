@@ -0,0 +1,185 @@
+package radio
+
+import (
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+// ---------------------------------------------------------------------------
+// Frame parsing for the encoded streams. MP3 and ADTS (AAC) streams are a
+// plain sequence of self-delimiting frames, so knowing where frames start
+// lets us cut segments, align bursts and drop audio without corrupting it.
+// ---------------------------------------------------------------------------
+
+// audioFrame is one complete encoded frame and the playback time it covers.
+type audioFrame struct {
+	data       []byte
+	samples    int
+	sampleRate int
+	duration   time.Duration
+}
+
+var (
+	mp3BitratesV1 = [15]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3BitratesV2 = [15]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+
+	mp3SampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG-1
+		2: {22050, 24000, 16000}, // MPEG-2
+		0: {11025, 12000, 8000},  // MPEG-2.5
+	}
+
+	adtsSampleRates = [13]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+)
+
+// parseMP3Header decodes an MPEG audio Layer III frame header. It returns the
+// frame size in bytes, the number of PCM samples it holds and its sample rate.
+func parseMP3Header(b []byte) (size, samples, sampleRate int, ok bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return 0, 0, 0, false
+	}
+	version := int(b[1]>>3) & 0x3
+	layer := int(b[1]>>1) & 0x3
+	if version == 1 || layer != 1 { // reserved version, or not Layer III
+		return 0, 0, 0, false
+	}
+	bitrateIdx := int(b[2] >> 4)
+	srIdx := int(b[2]>>2) & 0x3
+	if bitrateIdx == 0 || bitrateIdx == 15 || srIdx == 3 {
+		return 0, 0, 0, false
+	}
+	padding := int(b[2]>>1) & 0x1
+	sampleRate = mp3SampleRates[version][srIdx]
+
+	if version == 3 {
+		bitrate := mp3BitratesV1[bitrateIdx] * 1000
+		return 144*bitrate/sampleRate + padding, 1152, sampleRate, true
+	}
+	bitrate := mp3BitratesV2[bitrateIdx] * 1000
+	return 72*bitrate/sampleRate + padding, 576, sampleRate, true
+}
+
+// parseADTSHeader decodes an ADTS (AAC) frame header.
+func parseADTSHeader(b []byte) (size, samples, sampleRate int, ok bool) {
+	if len(b) < 7 || b[0] != 0xFF || b[1]&0xF6 != 0xF0 {
+		return 0, 0, 0, false
+	}
+	srIdx := int(b[2]>>2) & 0xF
+	if srIdx >= len(adtsSampleRates) {
+		return 0, 0, 0, false
+	}
+	size = int(b[3]&0x3)<<11 | int(b[4])<<3 | int(b[5]>>5)
+	if size < 7 {
+		return 0, 0, 0, false
+	}
+	blocks := int(b[6]&0x3) + 1
+	return size, 1024 * blocks, adtsSampleRates[srIdx], true
+}
+
+// id3TagSize returns the total length of an ID3v2 tag at the start of b, or
+// 0 if there is none. ok is false while the tag header is incomplete.
+func id3TagSize(b []byte) (size int, ok bool) {
+	if len(b) < 3 || string(b[:3]) != "ID3" {
+		return 0, true
+	}
+	if len(b) < 10 {
+		return 0, false
+	}
+	size = 10 + (int(b[6]&0x7F)<<21 | int(b[7]&0x7F)<<14 | int(b[8]&0x7F)<<7 | int(b[9]&0x7F))
+	if b[5]&0x10 != 0 { // footer present
+		size += 10
+	}
+	return size, true
+}
+
+// frameSplitter turns an arbitrary byte stream into whole frames. Bytes that
+// do not belong to a frame (ID3 tags, garbage while resyncing) are dropped.
+type frameSplitter struct {
+	parse func([]byte) (int, int, int, bool)
+	buf   []byte
+}
+
+// newFrameSplitter returns a splitter for codec, or nil if the codec is not
+// a plain frame sequence (Ogg pages are handled separately).
+func newFrameSplitter(codec ffmpeg.Codec) *frameSplitter {
+	switch codec {
+	case ffmpeg.CodecMP3:
+		return &frameSplitter{parse: parseMP3Header}
+	case ffmpeg.CodecAAC:
+		return &frameSplitter{parse: parseADTSHeader}
+	default:
+		return nil
+	}
+}
+
+// Split appends p to the internal buffer and returns every frame that is now
+// complete. Partial trailing data is kept for the next call.
+func (fs *frameSplitter) Split(p []byte) []audioFrame {
+	fs.buf = append(fs.buf, p...)
+
+	var frames []audioFrame
+	pos := 0
+	for pos < len(fs.buf) {
+		rest := fs.buf[pos:]
+
+		tagSize, ok := id3TagSize(rest)
+		if !ok {
+			break
+		}
+		if tagSize > 0 {
+			if len(rest) < tagSize {
+				break
+			}
+			pos += tagSize
+			continue
+		}
+
+		size, samples, rate, ok := fs.parse(rest)
+		if !ok {
+			if len(rest) < 7 {
+				break
+			}
+			pos++ // resync one byte at a time
+			continue
+		}
+		if len(rest) < size {
+			break
+		}
+
+		data := make([]byte, size)
+		copy(data, rest[:size])
+		frames = append(frames, audioFrame{
+			data:       data,
+			samples:    samples,
+			sampleRate: rate,
+			duration:   time.Duration(samples) * time.Second / time.Duration(rate),
+		})
+		pos += size
+	}
+
+	fs.buf = append(fs.buf[:0], fs.buf[pos:]...)
+	return frames
+}
+
+// chunkFromFrames packs frames into a single streamChunk.
+func chunkFromFrames(frames []audioFrame) streamChunk {
+	c := streamChunk{data: joinFrames(frames), frames: len(frames)}
+	for _, f := range frames {
+		c.duration += f.duration
+	}
+	return c
+}
+
+// joinFrames concatenates the data of frames into a single chunk.
+func joinFrames(frames []audioFrame) []byte {
+	n := 0
+	for _, f := range frames {
+		n += len(f.data)
+	}
+	buf := make([]byte, 0, n)
+	for _, f := range frames {
+		buf = append(buf, f.data...)
+	}
+	return buf
+}
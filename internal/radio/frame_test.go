@@ -0,0 +1,160 @@
+package radio
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+func TestID3TagSize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		size int
+		ok   bool
+	}{
+		{"no tag", []byte{0xFF, 0xFB, 0x90, 0x00}, 0, true},
+		{"too short to tell", []byte("ID"), 0, true},
+		{"incomplete header", []byte("ID3\x04\x00\x00\x00"), 0, false},
+		{"empty tag", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), 10, true},
+		{"low byte only", []byte("ID3\x04\x00\x00\x00\x00\x00\x7F"), 10 + 127, true},
+		{"synchsafe 257", []byte("ID3\x04\x00\x00\x00\x00\x02\x01"), 10 + 257, true},
+		{"every byte", []byte("ID3\x04\x00\x00\x01\x02\x03\x04"), 10 + (1<<21 | 2<<14 | 3<<7 | 4), true},
+		{"largest", []byte("ID3\x04\x00\x00\x7F\x7F\x7F\x7F"), 10 + (1<<28 - 1), true},
+		{"high bits ignored", []byte("ID3\x04\x00\x00\x80\x80\x81\x80"), 10 + 1<<7, true},
+		{"footer", []byte("ID3\x04\x00\x10\x00\x00\x00\x05"), 10 + 5 + 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, ok := id3TagSize(tt.in)
+			if size != tt.size || ok != tt.ok {
+				t.Fatalf("id3TagSize = (%d, %v), want (%d, %v)", size, ok, tt.size, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseMP3Header(t *testing.T) {
+	tests := []struct {
+		name       string
+		in         []byte
+		size       int
+		samples    int
+		sampleRate int
+		ok         bool
+	}{
+		{"MPEG-1 128k 44.1k", []byte{0xFF, 0xFB, 0x90, 0x00}, 417, 1152, 44100, true},
+		{"MPEG-1 128k 44.1k padded", []byte{0xFF, 0xFB, 0x92, 0x00}, 418, 1152, 44100, true},
+		{"MPEG-1 320k 48k", []byte{0xFF, 0xFB, 0xE4, 0x00}, 960, 1152, 48000, true},
+		{"MPEG-2 64k 24k", []byte{0xFF, 0xF3, 0x84, 0x00}, 192, 576, 24000, true},
+		{"free bitrate", []byte{0xFF, 0xFB, 0x00, 0x00}, 0, 0, 0, false},
+		{"reserved sample rate", []byte{0xFF, 0xFB, 0x9C, 0x00}, 0, 0, 0, false},
+		{"layer II", []byte{0xFF, 0xFD, 0x90, 0x00}, 0, 0, 0, false},
+		{"no sync", []byte{0x00, 0xFB, 0x90, 0x00}, 0, 0, 0, false},
+		{"short", []byte{0xFF, 0xFB}, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, samples, rate, ok := parseMP3Header(tt.in)
+			if size != tt.size || samples != tt.samples || rate != tt.sampleRate || ok != tt.ok {
+				t.Fatalf("parseMP3Header = (%d, %d, %d, %v), want (%d, %d, %d, %v)",
+					size, samples, rate, ok, tt.size, tt.samples, tt.sampleRate, tt.ok)
+			}
+		})
+	}
+}
+
+// mp3Frame returns a 128 kbps, 44.1 kHz MPEG-1 Layer III frame whose body
+// is filled with fill.
+func mp3Frame(padded bool, fill byte) []byte {
+	size, hdr2 := 417, byte(0x90)
+	if padded {
+		size, hdr2 = 418, 0x92
+	}
+	f := bytes.Repeat([]byte{fill}, size)
+	f[0], f[1], f[2], f[3] = 0xFF, 0xFB, hdr2, 0x00
+	return f
+}
+
+func TestFrameSplitterChunkBoundaries(t *testing.T) {
+	frames := [][]byte{mp3Frame(false, 1), mp3Frame(true, 2), mp3Frame(false, 3), mp3Frame(true, 4)}
+	var stream []byte
+	stream = append(stream, "ID3\x04\x00\x00\x00\x00\x00\x14"...) // 20-byte tag body
+	stream = append(stream, bytes.Repeat([]byte{0xAA}, 20)...)
+	for _, f := range frames {
+		stream = append(stream, f...)
+	}
+
+	for _, chunk := range []int{1, 3, 7, 100, 417, 418, 1000, len(stream)} {
+		fs := newFrameSplitter(ffmpeg.CodecMP3)
+		var got []audioFrame
+		for i := 0; i < len(stream); i += chunk {
+			got = append(got, fs.Split(stream[i:min(i+chunk, len(stream))])...)
+		}
+		if len(got) != len(frames) {
+			t.Fatalf("chunk %d: %d frames, want %d", chunk, len(got), len(frames))
+		}
+		for i, f := range got {
+			if !bytes.Equal(f.data, frames[i]) {
+				t.Fatalf("chunk %d: frame %d differs", chunk, i)
+			}
+			if f.samples != 1152 || f.sampleRate != 44100 {
+				t.Fatalf("chunk %d: frame %d has %d samples at %d Hz", chunk, i, f.samples, f.sampleRate)
+			}
+		}
+		if len(fs.buf) != 0 {
+			t.Fatalf("chunk %d: %d bytes left over", chunk, len(fs.buf))
+		}
+	}
+}
+
+func TestFrameSplitterKeepsPartialFrame(t *testing.T) {
+	fs := newFrameSplitter(ffmpeg.CodecMP3)
+	first, second := mp3Frame(false, 1), mp3Frame(false, 2)
+
+	got := fs.Split(append(append([]byte(nil), first...), second[:200]...))
+	if len(got) != 1 || !bytes.Equal(got[0].data, first) {
+		t.Fatalf("got %d frames, want only the complete one", len(got))
+	}
+	if len(fs.buf) != 200 {
+		t.Fatalf("kept %d bytes, want the 200 of the partial frame", len(fs.buf))
+	}
+
+	got = fs.Split(second[200:])
+	if len(got) != 1 || !bytes.Equal(got[0].data, second) {
+		t.Fatal("partial frame was not completed by the next write")
+	}
+}
+
+func TestFrameSplitterResyncsAfterGarbage(t *testing.T) {
+	fs := newFrameSplitter(ffmpeg.CodecMP3)
+	frame := mp3Frame(false, 5)
+	stream := append([]byte{0x00, 0x12, 0xFF, 0x00, 0x34, 0x56, 0x78, 0x9A}, frame...)
+
+	got := fs.Split(stream)
+	if len(got) != 1 || !bytes.Equal(got[0].data, frame) {
+		t.Fatalf("got %d frames, want the frame after the garbage", len(got))
+	}
+}
+
+func TestFrameSplitterADTS(t *testing.T) {
+	// AAC-LC, 44.1 kHz, stereo, 100-byte frames.
+	frame := make([]byte, 100)
+	frame[0], frame[1] = 0xFF, 0xF1
+	frame[2] = 1<<6 | 4<<2
+	frame[3] = 2<<6 | byte(len(frame)>>11)&0x3
+	frame[4] = byte(len(frame) >> 3)
+	frame[5] = byte(len(frame)&0x7)<<5 | 0x1F
+	frame[6] = 0xFC
+
+	stream := append(append([]byte(nil), frame...), frame...)
+	fs := newFrameSplitter(ffmpeg.CodecAAC)
+	got := append(fs.Split(stream[:150]), fs.Split(stream[150:])...)
+	if len(got) != 2 {
+		t.Fatalf("got %d frames, want 2", len(got))
+	}
+	if got[0].samples != 1024 || got[0].sampleRate != 44100 {
+		t.Fatalf("frame has %d samples at %d Hz, want 1024 at 44100", got[0].samples, got[0].sampleRate)
+	}
+}
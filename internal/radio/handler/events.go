@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/events"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// eventKeepAlive is how often an idle SSE stream sends a comment so proxies
+// do not close it.
+const eventKeepAlive = 20 * time.Second
+
+// EventHandlers holds the gin route handler for the real-time event stream.
+type EventHandlers struct {
+	bus   *events.Bus
+	radio *service.RadioService
+}
+
+func NewEventHandlers(bus *events.Bus, radio *service.RadioService) *EventHandlers {
+	return &EventHandlers{bus: bus, radio: radio}
+}
+
+// eventMessage is the wire format shared by SSE and WebSocket clients.
+type eventMessage struct {
+	Type events.Type `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// Stream handles GET /api/events  (public)
+//
+// Clients that send a WebSocket upgrade get a WebSocket, everyone else gets
+// Server-Sent Events. The optional ?types= query is a comma-separated list
+// of event types to receive. Every client first receives a "state" event
+// with the full station status.
+func (h *EventHandlers) Stream(c *gin.Context) {
+	filter, err := events.ParseTypes(c.Query("types"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+
+	// Subscribe before taking the snapshot so no change falls in between.
+	sub := h.bus.Subscribe(filter)
+	defer h.bus.Unsubscribe(sub)
+	state := eventMessage{Type: events.TypeState, Time: time.Now().UTC(), Data: statusBody(h.radio.Status())}
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		h.serveWebSocket(c, sub, state)
+		return
+	}
+	h.serveSSE(c, sub, state)
+}
+
+func (h *EventHandlers) serveSSE(c *gin.Context, sub *events.Subscription, state eventMessage) {
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeSSE(w, state); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := writeSSE(w, renderEvent(ev)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeSSE(w gin.ResponseWriter, msg eventMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("Failed to encode event", "event", msg.Type, "error", err)
+		return nil
+	}
+	if _, err := io.WriteString(w, "event: "+string(msg.Type)+"\ndata: "+string(data)+"\n\n"); err != nil {
+		return err
+	}
+	w.Flush()
+	return nil
+}
+
+func (h *EventHandlers) serveWebSocket(c *gin.Context, sub *events.Subscription, state eventMessage) {
+	server := websocket.Server{
+		// Overlays and other non-browser clients send no Origin; the events
+		// are as public as /api/status, so any origin is accepted.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			// Incoming messages are ignored; reading only notices the
+			// client going away.
+			closed := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, ws)
+				close(closed)
+			}()
+
+			if err := websocket.JSON.Send(ws, state); err != nil {
+				return
+			}
+			for {
+				select {
+				case <-closed:
+					return
+				case ev, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					if err := websocket.JSON.Send(ws, renderEvent(ev)); err != nil {
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// renderEvent converts an event to its wire format, stripping file-system
+// paths from tracks like every other endpoint.
+func renderEvent(ev events.Event) eventMessage {
+	var data gin.H
+	switch d := ev.Data.(type) {
+	case events.TrackStarted:
+		var playlistID *int64
+		var playlistName string
+		if d.Playlist != nil {
+			playlistID = &d.Playlist.ID
+			playlistName = d.Playlist.Name
+		}
+		progress := service.TrackProgress{StartedAt: d.StartedAt, Elapsed: d.Offset.Seconds()}
+		if d.Track.Duration > 0 {
+			remaining := float64(d.Track.Duration) - progress.Elapsed
+			progress.Remaining = &remaining
+		}
+		data = gin.H{
+			"track":       sanitiseTrack(d.Track),
+			"playlist":    playlistName,
+			"playlist_id": playlistID,
+			"live":        d.Live,
+			"progress":    progress,
+		}
+	case events.ListenersChanged:
+		data = gin.H{
+			"active_clients":  d.Total,
+			"mount":           d.Mount,
+			"mount_listeners": d.MountListeners,
+		}
+	case events.TagSwitched:
+		var playlistID *int64
+		var playlistName string
+		if d.Playlist != nil {
+			playlistID = &d.Playlist.ID
+			playlistName = d.Playlist.Name
+		}
+		data = gin.H{
+			"previous_tag":       d.Previous,
+			"active_tag":         d.Current,
+			"active_day":         strings.ToLower(d.Day.String()),
+			"override":           d.Override,
+			"hard_start":         d.HardStart,
+			"active_playlist":    playlistName,
+			"active_playlist_id": playlistID,
+		}
+	case events.PlaylistModified:
+		var playlistID *int64
+		if d.PlaylistID != 0 {
+			playlistID = &d.PlaylistID
+		}
+		data = gin.H{
+			"action":      d.Action,
+			"playlist_id": playlistID,
+		}
+	case events.SkipRequested:
+		data = gin.H{"direction": d.Direction}
+	}
+	return eventMessage{Type: ev.Type, Time: ev.Time, Data: data}
+}
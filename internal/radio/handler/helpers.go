@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
 )
@@ -18,18 +19,31 @@ func parseID(s string) (int64, error) {
 // sanitiseTrack returns a map representation of a track with the absolute
 // file-system path replaced by just the filename, preventing server path leaks.
 func sanitiseTrack(t *playlist.Track) map[string]interface{} {
+	var addedAt *time.Time
+	if !t.AddedAt.IsZero() {
+		addedAt = &t.AddedAt
+	}
 	return map[string]interface{}{
-		"id":       t.ID,
-		"title":    t.Title,
-		"artist":   t.Artist,
-		"album":    t.Album,
-		"genre":    t.Genre,
-		"year":     t.Year,
-		"trackNum": t.TrackNum,
-		"duration": t.Duration,
-		"filePath": filepath.Base(t.FilePath),
-		"format":   t.Format,
-		"checksum": t.Checksum,
+		"id":         t.ID,
+		"title":      t.Title,
+		"artist":     t.Artist,
+		"album":      t.Album,
+		"genre":      t.Genre,
+		"year":       t.Year,
+		"trackNum":   t.TrackNum,
+		"duration":   t.Duration,
+		"filePath":   filepath.Base(t.FilePath),
+		"format":     t.Format,
+		"checksum":   t.Checksum,
+		"loudness":   t.Loudness,
+		"truePeak":   t.TruePeak,
+		"codec":      t.Codec,
+		"bitrate":    t.Bitrate,
+		"sampleRate": t.SampleRate,
+		"channels":   t.Channels,
+		"corrupt":    t.Corrupt,
+		"probeError": t.ProbeError,
+		"addedAt":    addedAt,
 	}
 }
 
@@ -49,7 +63,7 @@ func isNotFound(err error) bool {
 
 // isValidationError detects validation / bad-request type errors.
 func isValidationError(err error) bool {
-	return err != nil && containsAny(err.Error(), "invalid tag", "name is required", "must be one of")
+	return err != nil && containsAny(err.Error(), "invalid tag", "name is required", "must be one of", "invalid crossfade", "invalid time", "invalid day", "invalid override", "invalid rule")
 }
 
 // isForbidden detects path-traversal / forbidden errors.
@@ -57,6 +71,11 @@ func isForbidden(err error) bool {
 	return err != nil && containsAny(err.Error(), "within the music directory", "forbidden")
 }
 
+// isConflict detects operations refused because a resource is in use.
+func isConflict(err error) bool {
+	return err != nil && containsAny(err.Error(), "still being written", "already exists", "still has playlists", "is a smart playlist")
+}
+
 func containsAny(s string, substrs ...string) bool {
 	for _, sub := range substrs {
 		for i := 0; i <= len(s)-len(sub); i++ {
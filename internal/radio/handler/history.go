@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+	"github.com/gin-gonic/gin"
+)
+
+// HistoryHandlers holds the gin route handlers for the play history.
+type HistoryHandlers struct {
+	svc *service.HistoryService
+}
+
+func NewHistoryHandlers(svc *service.HistoryService) *HistoryHandlers {
+	return &HistoryHandlers{svc: svc}
+}
+
+// List handles GET /api/history?from=&to=&offset=&limit=  (public)
+//
+// File paths are left out; use Full for the complete record.
+func (h *HistoryHandlers) List(c *gin.Context) {
+	h.list(c, false)
+}
+
+// Full handles GET /api/history/full?from=&to=&offset=&limit=  (protected)
+func (h *HistoryHandlers) Full(c *gin.Context) {
+	h.list(c, true)
+}
+
+func (h *HistoryHandlers) list(c *gin.Context, withPaths bool) {
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid " + p.name + ": must be an RFC 3339 time"})
+				return
+			}
+			*p.dst = t
+		}
+	}
+	offset, limit := 0, 50
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid offset"})
+			return
+		}
+		offset = n
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	plays, total, err := h.svc.List(from, to, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	if !withPaths {
+		for i := range plays {
+			plays[i].FilePath = ""
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+		"count":  len(plays),
+		"plays":  plays,
+	})
+}
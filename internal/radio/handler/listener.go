@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ListenerHandlers holds the gin route handlers for listener session and
+// statistics endpoints.
+type ListenerHandlers struct {
+	svc *service.ListenerService
+}
+
+func NewListenerHandlers(svc *service.ListenerService) *ListenerHandlers {
+	return &ListenerHandlers{svc: svc}
+}
+
+// List handles GET /api/listeners  (protected)
+func (h *ListenerHandlers) List(c *gin.Context) {
+	sessions := h.svc.Active()
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "ok",
+		"count":     len(sessions),
+		"listeners": sessions,
+	})
+}
+
+// History handles GET /api/listeners/history?limit=N  (protected)
+func (h *ListenerHandlers) History(c *gin.Context) {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	sessions := h.svc.History(limit)
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"count":    len(sessions),
+		"sessions": sessions,
+	})
+}
+
+// Stats handles GET /api/listeners/stats?days=N  (protected)
+func (h *ListenerHandlers) Stats(c *gin.Context) {
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid days"})
+			return
+		}
+		days = n
+	}
+	stats, err := h.svc.DailyStats(days)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	total := 0.0
+	for _, d := range stats {
+		total += d.ListeningHours
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ok",
+		"days":        stats,
+		"total_hours": total,
+	})
+}
+
+// Kick handles DELETE /api/listeners/:id  (protected)
+func (h *ListenerHandlers) Kick(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.svc.Kick(id); err != nil {
+		status := http.StatusInternalServerError
+		if isNotFound(err) {
+			status = http.StatusNotFound
+		} else {
+			slog.Error("Failed to kick listener", "error", err, "session_id", id)
+		}
+		c.JSON(status, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	slog.Info("Listener kicked", "session_id", id)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "message": "listener disconnected"})
+}
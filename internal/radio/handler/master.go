@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
 	"github.com/arung-agamani/denpa-radio/internal/radio/service"
 	"github.com/gin-gonic/gin"
 )
@@ -26,21 +28,29 @@ func (h *MasterHandlers) Get(c *gin.Context) {
 		"active_tag":         snap.ActiveTag,
 		"active_playlist_id": snap.ActivePlaylistID,
 		"total_tracks":       snap.TotalTracks,
+		"active_day":         strings.ToLower(snap.ActiveDay.String()),
+		"slots":              snap.Slots,
 		"tags":               snap.Tags,
 	})
 }
 
+// Week handles GET /api/master/week
+func (h *MasterHandlers) Week(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "week": h.svc.Week()})
+}
+
 // AssignPlaylistToTag handles PUT /api/master/:tag  (protected)
 func (h *MasterHandlers) AssignPlaylistToTag(c *gin.Context) {
 	tagStr := c.Param("tag")
 	var body struct {
-		PlaylistID int64 `json:"playlistId"`
+		PlaylistID int64    `json:"playlistId"`
+		Days       []string `json:"days"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
 		return
 	}
-	if err := h.svc.AssignPlaylistToTag(body.PlaylistID, tagStr); err != nil {
+	if err := h.svc.AssignPlaylistToTag(body.PlaylistID, tagStr, body.Days); err != nil {
 		slog.Error("Failed to assign playlist to tag", "error", err)
 		status := http.StatusInternalServerError
 		if isNotFound(err) {
@@ -80,3 +90,131 @@ func (h *MasterHandlers) RemovePlaylistFromTag(c *gin.Context) {
 		"message": fmt.Sprintf("playlist %d removed from tag %s", plID, tagStr),
 	})
 }
+
+// ListSlots handles GET /api/master/slots
+func (h *MasterHandlers) ListSlots(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "slots": h.svc.ListSlots()})
+}
+
+// CreateSlot handles POST /api/master/slots  (protected)
+func (h *MasterHandlers) CreateSlot(c *gin.Context) {
+	var body struct {
+		Name      string `json:"name"`
+		Start     string `json:"start"`
+		End       string `json:"end"`
+		HardStart bool   `json:"hardStart"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
+		return
+	}
+	slot, err := h.svc.CreateSlot(body.Name, body.Start, body.End, body.HardStart)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "ok", "slot": slot})
+}
+
+// UpdateSlot handles PUT /api/master/slots/:name  (protected)
+func (h *MasterHandlers) UpdateSlot(c *gin.Context) {
+	var body struct {
+		Name      *string `json:"name"`
+		Start     *string `json:"start"`
+		End       *string `json:"end"`
+		HardStart *bool   `json:"hardStart"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
+		return
+	}
+	slot, err := h.svc.UpdateSlot(c.Param("name"), service.SlotUpdate{Name: body.Name, Start: body.Start, End: body.End, HardStart: body.HardStart})
+	if err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "slot": slot})
+}
+
+// DeleteSlot handles DELETE /api/master/slots/:name  (protected)
+func (h *MasterHandlers) DeleteSlot(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.svc.DeleteSlot(name); err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("time slot %s deleted", name),
+	})
+}
+
+// ListOverrides handles GET /api/master/overrides
+func (h *MasterHandlers) ListOverrides(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "overrides": h.svc.ListOverrides()})
+}
+
+// CreateOverride handles POST /api/master/overrides  (protected)
+func (h *MasterHandlers) CreateOverride(c *gin.Context) {
+	var body playlist.ScheduleOverride
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
+		return
+	}
+	o, err := h.svc.CreateOverride(body)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"status": "ok", "override": o})
+}
+
+// UpdateOverride handles PUT /api/master/overrides/:id  (protected)
+func (h *MasterHandlers) UpdateOverride(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid override ID"})
+		return
+	}
+	var body playlist.ScheduleOverride
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
+		return
+	}
+	o, err := h.svc.UpdateOverride(id, body)
+	if err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "override": o})
+}
+
+// DeleteOverride handles DELETE /api/master/overrides/:id  (protected)
+func (h *MasterHandlers) DeleteOverride(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid override ID"})
+		return
+	}
+	if err := h.svc.DeleteOverride(id); err != nil {
+		c.JSON(slotErrorStatus(err), gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": fmt.Sprintf("override %d deleted", id),
+	})
+}
+
+func slotErrorStatus(err error) int {
+	switch {
+	case isNotFound(err):
+		return http.StatusNotFound
+	case isConflict(err):
+		return http.StatusConflict
+	case isValidationError(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
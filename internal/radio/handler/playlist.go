@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
 	"github.com/arung-agamani/denpa-radio/internal/radio/service"
 	"github.com/gin-gonic/gin"
 )
@@ -42,14 +43,15 @@ func (h *PlaylistHandlers) GetByID(c *gin.Context) {
 // Create handles POST /api/playlists  (protected)
 func (h *PlaylistHandlers) Create(c *gin.Context) {
 	var body struct {
-		Name string `json:"name"`
-		Tag  string `json:"tag"`
+		Name string              `json:"name"`
+		Tag  string              `json:"tag"`
+		Rule *playlist.SmartRule `json:"rule"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
 		return
 	}
-	pl, err := h.svc.Create(body.Name, body.Tag)
+	pl, err := h.svc.Create(body.Name, body.Tag, body.Rule)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": err.Error()})
 		return
@@ -65,14 +67,16 @@ func (h *PlaylistHandlers) Update(c *gin.Context) {
 		return
 	}
 	var body struct {
-		Name *string `json:"name"`
-		Tag  *string `json:"tag"`
+		Name             *string             `json:"name"`
+		Tag              *string             `json:"tag"`
+		CrossfadeSeconds *float64            `json:"crossfadeSeconds"`
+		Rule             *playlist.SmartRule `json:"rule"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
 		return
 	}
-	pl, err := h.svc.Update(id, body.Name, body.Tag)
+	pl, err := h.svc.Update(id, body.Name, body.Tag, body.CrossfadeSeconds, body.Rule)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if isNotFound(err) {
@@ -134,6 +138,8 @@ func (h *PlaylistHandlers) AddTrack(c *gin.Context) {
 			status = http.StatusNotFound
 		} else if isForbidden(err) {
 			status = http.StatusForbidden
+		} else if isConflict(err) {
+			status = http.StatusConflict
 		}
 		c.JSON(status, gin.H{"status": "error", "error": err.Error()})
 		return
@@ -158,6 +164,8 @@ func (h *PlaylistHandlers) RemoveTrack(c *gin.Context) {
 		status := http.StatusInternalServerError
 		if isNotFound(err) {
 			status = http.StatusNotFound
+		} else if isConflict(err) {
+			status = http.StatusConflict
 		}
 		c.JSON(status, gin.H{"status": "error", "error": err.Error()})
 		return
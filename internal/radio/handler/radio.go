@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"net/http"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/arung-agamani/denpa-radio/internal/radio/service"
 	"github.com/gin-gonic/gin"
@@ -26,19 +28,33 @@ func (h *RadioHandlers) Health(c *gin.Context) {
 
 // Status handles GET /api/status  (and legacy GET /status)
 func (h *RadioHandlers) Status(c *gin.Context) {
-	snap := h.svc.Status()
+	c.JSON(http.StatusOK, statusBody(h.svc.Status()))
+}
+
+// statusBody renders a status snapshot; it is also the payload of the
+// "state" event sent to new event-stream clients.
+func statusBody(snap service.StatusSnapshot) gin.H {
 	var currentTrackInfo interface{}
 	if snap.CurrentTrackRaw != nil {
 		currentTrackInfo = sanitiseTrack(snap.CurrentTrackRaw)
 	}
-	c.JSON(http.StatusOK, gin.H{
+	return gin.H{
 		"station_name":       snap.StationName,
 		"current_track":      snap.CurrentTrack,
 		"current_track_info": currentTrackInfo,
+		"progress":           snap.Progress,
 		"total_tracks":       snap.TotalTracks,
 		"library_tracks":     snap.LibraryTracks,
 		"active_clients":     snap.ActiveClients,
 		"max_clients":        snap.MaxClients,
+		"mounts":             snap.Mounts,
+		"fallback_active":    snap.FallbackReason != "",
+		"fallback_reason":    snap.FallbackReason,
+		"live":               snap.Live,
+		"relays":             snap.Relays,
+		"jingle":             snap.Jingle,
+		"paused":             snap.Pause != nil,
+		"pause":              snap.Pause,
 		"active_tag":         snap.ActiveTag,
 		"active_playlist":    snap.ActivePlaylist,
 		"active_playlist_id": snap.ActivePlaylistID,
@@ -46,18 +62,32 @@ func (h *RadioHandlers) Status(c *gin.Context) {
 		"playlist_summary":   snap.PlaylistSummary,
 		"timezone":           snap.Timezone,
 		"server_time":        snap.ServerTime,
-	})
+	}
 }
 
 // SchedulerStatus handles GET /api/scheduler/status
 func (h *RadioHandlers) SchedulerStatus(c *gin.Context) {
 	snap := h.svc.SchedulerStatus()
+	var override gin.H
+	if snap.Override != nil {
+		override = gin.H{
+			"id":      snap.Override.ID,
+			"name":    snap.Override.Name,
+			"start":   snap.Override.Start,
+			"end":     snap.Override.End,
+			"yearly":  snap.Override.Yearly,
+			"ends_at": snap.OverrideEnds.Format(time.RFC3339),
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":         "ok",
 		"running":        snap.Running,
 		"last_tag":       snap.LastTag,
 		"time_tags":      snap.TimeTags,
+		"time_slots":     snap.Slots,
 		"current_tag":    snap.CurrentTag,
+		"active_day":     strings.ToLower(snap.ActiveDay.String()),
+		"override":       override,
 		"summary":        snap.Summary,
 		"library_tracks": snap.LibraryTracks,
 		"timezone":       snap.Timezone,
@@ -171,6 +201,24 @@ func (h *RadioHandlers) SkipPrev(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// Pause handles POST /api/pause  (protected)
+func (h *RadioHandlers) Pause(c *gin.Context) {
+	if err := h.svc.Pause(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Resume handles POST /api/resume  (protected)
+func (h *RadioHandlers) Resume(c *gin.Context) {
+	if err := h.svc.Resume(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
 // LegacyReload handles POST /playlist/reload  (protected, backwards compat)
 func (h *RadioHandlers) LegacyReload(c *gin.Context) {
 	slog.Info("Playlist reload requested (legacy)")
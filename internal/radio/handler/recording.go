@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RecordingHandlers holds the gin route handlers for the stream archive.
+type RecordingHandlers struct {
+	svc *service.RecordingService
+}
+
+func NewRecordingHandlers(svc *service.RecordingService) *RecordingHandlers {
+	return &RecordingHandlers{svc: svc}
+}
+
+// List handles GET /api/recordings  (protected)
+func (h *RecordingHandlers) List(c *gin.Context) {
+	recs, err := h.svc.List()
+	if err != nil {
+		slog.Error("Failed to list recordings", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "ok",
+		"count":      len(recs),
+		"recordings": recs,
+	})
+}
+
+// Get handles GET /api/recordings/:name  (protected)
+func (h *RecordingHandlers) Get(c *gin.Context) {
+	rec, err := h.svc.Get(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "recording": rec})
+}
+
+// Download handles GET /api/recordings/:name/download  (protected)
+func (h *RecordingHandlers) Download(c *gin.Context) {
+	name := c.Param("name")
+	path, err := h.svc.FilePath(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// Delete handles DELETE /api/recordings/:name  (protected)
+func (h *RecordingHandlers) Delete(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.svc.Delete(name); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case isNotFound(err):
+			status = http.StatusNotFound
+		case isConflict(err):
+			status = http.StatusConflict
+		default:
+			slog.Error("Failed to delete recording", "error", err, "name", name)
+		}
+		c.JSON(status, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	slog.Info("Recording deleted", "name", name)
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "message": "recording deleted"})
+}
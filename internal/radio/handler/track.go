@@ -3,6 +3,7 @@ package handler
 import (
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strings"
 
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
@@ -130,16 +131,57 @@ func (h *TrackHandlers) Delete(c *gin.Context) {
 // Scan handles POST /api/tracks/scan  (protected)
 func (h *TrackHandlers) Scan(c *gin.Context) {
 	slog.Info("Track library scan requested", "remote", c.ClientIP())
-	added, total, err := h.svc.Scan()
+	added, total, corrupt, err := h.svc.Scan()
 	if err != nil {
 		slog.Error("Library scan failed", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": "failed to scan music directory"})
 		return
 	}
+	corruptFiles := make([]gin.H, 0, len(corrupt))
+	for _, t := range corrupt {
+		corruptFiles = append(corruptFiles, gin.H{"file": filepath.Base(t.FilePath), "error": t.ProbeError})
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":        "ok",
 		"newly_added":   added,
 		"library_total": total,
+		"corrupt":       corruptFiles,
+	})
+}
+
+// AnalyzeLoudness handles POST /api/tracks/loudness  (protected)
+//
+// Queues a background EBU R128 analysis of every track that has not been
+// measured yet, or of every track when the body sets "force": true.
+func (h *TrackHandlers) AnalyzeLoudness(c *gin.Context) {
+	var body struct {
+		Force bool `json:"force"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "invalid request body"})
+			return
+		}
+	}
+	queued, err := h.svc.AnalyzeLoudness(body.Force)
+	if err != nil {
+		slog.Error("Failed to queue loudness analysis", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "error": err.Error()})
+		return
+	}
+	slog.Info("Loudness analysis requested", "remote", c.ClientIP(), "queued", queued, "force", body.Force)
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":   "ok",
+		"queued":   queued,
+		"progress": h.svc.LoudnessStatus(),
+	})
+}
+
+// LoudnessStatus handles GET /api/tracks/loudness  (protected)
+func (h *TrackHandlers) LoudnessStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "ok",
+		"progress": h.svc.LoudnessStatus(),
 	})
 }
 
@@ -213,6 +255,9 @@ func (h *TrackHandlers) Upload(c *gin.Context) {
 		if containsAny(err.Error(), "unsupported audio format") {
 			code = "UNSUPPORTED_FORMAT"
 			status = http.StatusUnprocessableEntity
+		} else if containsAny(err.Error(), "corrupt audio file") {
+			code = "CORRUPT_FILE"
+			status = http.StatusUnprocessableEntity
 		} else if containsAny(err.Error(), "outside the music directory") {
 			code = "FORBIDDEN"
 			status = http.StatusForbidden
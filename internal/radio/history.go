@@ -0,0 +1,44 @@
+package radio
+
+import (
+	"github.com/arung-agamani/denpa-radio/internal/history"
+)
+
+// SetHistory makes the broadcaster log every regular track it plays.
+func (b *Broadcaster) SetHistory(l *history.Log) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.history = l
+}
+
+// recordPlay logs the track that just finished, if it played at all. It is
+// called once the mixer has ended or cut the track; outro is the length of
+// PCM held back to crossfade into the next track, which still airs. Jingles,
+// live shows and legacy-mode tracks are not logged.
+func (b *Broadcaster) recordPlay(skipped bool, outro int) {
+	b.mu.RLock()
+	hist := b.history
+	b.mu.RUnlock()
+	c, _ := b.cursor.Load().(*playCursor)
+	if hist == nil || c == nil {
+		return
+	}
+	played := c.played() + c.duration(outro)
+	if played <= 0 {
+		return
+	}
+	hist.Record(history.Play{
+		TrackID:      c.track.ID,
+		Checksum:     c.checksum,
+		Title:        c.track.Title,
+		Artist:       c.track.Artist,
+		FilePath:     c.track.FilePath,
+		PlaylistID:   c.playlistID,
+		PlaylistName: c.playlistName,
+		Tag:          c.tag,
+		StartedAt:    c.startedAt,
+		Duration:     played.Seconds(),
+		Skipped:      skipped,
+		Listeners:    c.listeners,
+	})
+}
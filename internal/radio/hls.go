@@ -0,0 +1,347 @@
+package radio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// HLSPath is the URL prefix the HLS playlist and segments are served under.
+const HLSPath = "/hls"
+
+// hlsPlaylistName is the file name of the live media playlist.
+const hlsPlaylistName = "live.m3u8"
+
+// hlsRetainExtra is how many segments beyond the advertised window are kept
+// in memory, for clients still working through an older playlist.
+const hlsRetainExtra = 3
+
+// HLSConfig controls the rolling HLS output.
+type HLSConfig struct {
+	// Mount is the mount whose encoded audio is segmented. It must use a
+	// codec that can be served as packed audio (MP3 or AAC).
+	Mount string
+	// SegmentDuration is the target length of each segment.
+	SegmentDuration time.Duration
+	// Window is the number of segments advertised in the live playlist.
+	Window int
+}
+
+// hlsSegment is one finished media segment.
+type hlsSegment struct {
+	seq      uint64
+	duration time.Duration
+	data     []byte
+
+	// discontinuity is set on the first segment after an encoder restart;
+	// discSeq counts the discontinuities up to and including this segment.
+	discontinuity bool
+	discSeq       uint64
+}
+
+// HLSSegmenter subscribes to a mount like any listener and cuts its encoded
+// audio into packed-audio segments on frame boundaries. Each segment starts
+// with an ID3 tag carrying its timestamp and the current track; a further
+// ID3 tag is inserted mid-segment whenever the track changes.
+type HLSSegmenter struct {
+	broadcaster *Broadcaster
+	cfg         HLSConfig
+	codec       ffmpeg.Codec
+
+	mu       sync.RWMutex
+	segments []*hlsSegment
+	nextSeq  uint64
+	discSeq  uint64
+
+	// Segment being assembled; only touched by the Start goroutine.
+	cur      bytes.Buffer
+	curDur   time.Duration
+	curTitle string
+	curDisc  bool   // the segment follows an encoder restart
+	samples  uint64 // samples segmented so far, for the 90 kHz timestamps
+}
+
+// NewHLSSegmenter validates cfg against the broadcaster's mounts and returns
+// a segmenter for it.
+func NewHLSSegmenter(b *Broadcaster, cfg HLSConfig) (*HLSSegmenter, error) {
+	if cfg.SegmentDuration <= 0 {
+		return nil, fmt.Errorf("invalid HLS segment duration %s", cfg.SegmentDuration)
+	}
+	if cfg.Window < 1 {
+		return nil, fmt.Errorf("invalid HLS window %d: must be at least 1", cfg.Window)
+	}
+
+	b.mu.RLock()
+	m := b.mountUnsafe(cfg.Mount)
+	b.mu.RUnlock()
+	if m == nil {
+		return nil, fmt.Errorf("HLS mount %q not found", cfg.Mount)
+	}
+	codec := m.cfg.Format.Codec
+	if codec != ffmpeg.CodecMP3 && codec != ffmpeg.CodecAAC {
+		return nil, fmt.Errorf("HLS mount %q uses %s; only mp3 and aac are supported", cfg.Mount, codec)
+	}
+
+	return &HLSSegmenter{
+		broadcaster: b,
+		cfg:         cfg,
+		codec:       codec,
+	}, nil
+}
+
+// Start consumes the mount until ctx is cancelled.
+func (h *HLSSegmenter) Start(ctx context.Context) {
+	sub, err := h.broadcaster.subscribe(h.cfg.Mount, true)
+	if err != nil {
+		slog.Error("HLS segmenter failed to subscribe", "mount", h.cfg.Mount, "error", err)
+		return
+	}
+	defer h.broadcaster.Unsubscribe(sub)
+
+	slog.Info("HLS segmenter started",
+		"mount", h.cfg.Mount,
+		"segment_duration", h.cfg.SegmentDuration,
+		"window", h.cfg.Window,
+	)
+
+	splitter := newFrameSplitter(h.codec)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			sub.consumed(chunk)
+			if chunk.discontinuity {
+				// The encoder restarted: close the segment from the old
+				// stream and drop its partial frame.
+				if h.cur.Len() > 0 {
+					h.finishSegment()
+				}
+				h.curDisc = true
+				splitter = newFrameSplitter(h.codec)
+			}
+			for _, f := range splitter.Split(chunk.data) {
+				h.addFrame(f)
+			}
+		}
+	}
+}
+
+// addFrame appends one encoded frame to the current segment and closes the
+// segment once it reaches the target duration.
+func (h *HLSSegmenter) addFrame(f audioFrame) {
+	title := h.broadcaster.StreamTitle()
+	pts := h.samples * 90000 / uint64(f.sampleRate)
+	if h.cur.Len() == 0 {
+		h.cur.Write(buildHLSID3(pts, h.broadcaster.CurrentTrackInfo(), true))
+		h.curTitle = title
+	} else if title != h.curTitle {
+		h.cur.Write(buildHLSID3(pts, h.broadcaster.CurrentTrackInfo(), false))
+		h.curTitle = title
+	}
+
+	h.cur.Write(f.data)
+	h.curDur += f.duration
+	h.samples += uint64(f.samples)
+
+	if h.curDur >= h.cfg.SegmentDuration {
+		h.finishSegment()
+	}
+}
+
+// finishSegment publishes the current segment and trims old ones.
+func (h *HLSSegmenter) finishSegment() {
+	data := make([]byte, h.cur.Len())
+	copy(data, h.cur.Bytes())
+
+	h.mu.Lock()
+	if h.curDisc {
+		h.discSeq++
+	}
+	h.segments = append(h.segments, &hlsSegment{
+		seq:           h.nextSeq,
+		duration:      h.curDur,
+		data:          data,
+		discontinuity: h.curDisc,
+		discSeq:       h.discSeq,
+	})
+	h.nextSeq++
+	if keep := h.cfg.Window + hlsRetainExtra; len(h.segments) > keep {
+		h.segments = append(h.segments[:0], h.segments[len(h.segments)-keep:]...)
+	}
+	h.mu.Unlock()
+
+	h.cur.Reset()
+	h.curDur = 0
+	h.curDisc = false
+}
+
+// segmentExt returns the file extension used for segments of this codec.
+func (h *HLSSegmenter) segmentExt() string {
+	return h.codec.Extension()
+}
+
+// Playlist renders the live media playlist, or nil if no segment is ready
+// yet.
+func (h *HLSSegmenter) Playlist() []byte {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	segs := h.segments
+	if len(segs) == 0 {
+		return nil
+	}
+	if len(segs) > h.cfg.Window {
+		segs = segs[len(segs)-h.cfg.Window:]
+	}
+
+	target := int(math.Ceil(h.cfg.SegmentDuration.Seconds()))
+	for _, s := range segs {
+		if d := int(math.Ceil(s.duration.Seconds())); d > target {
+			target = d
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", segs[0].seq)
+	// Count the discontinuities that have scrolled out of the window.
+	disc := segs[0].discSeq
+	if segs[0].discontinuity {
+		disc--
+	}
+	if disc > 0 {
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", disc)
+	}
+	for _, s := range segs {
+		if s.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", s.duration.Seconds())
+		fmt.Fprintf(&b, "seg-%d%s\n", s.seq, h.segmentExt())
+	}
+	return []byte(b.String())
+}
+
+// Segment returns the segment with the given sequence number if it is still
+// retained.
+func (h *HLSSegmenter) Segment(seq uint64) ([]byte, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, s := range h.segments {
+		if s.seq == seq {
+			return s.data, true
+		}
+	}
+	return nil, false
+}
+
+// ServeHTTP serves the live playlist and its segments under HLSPath.
+func (h *HLSSegmenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+
+	if name == hlsPlaylistName {
+		body := h.Playlist()
+		if body == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(h.cfg.SegmentDuration.Seconds()))))
+			http.Error(w, "Stream not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache, no-store")
+		_, _ = w.Write(body)
+		return
+	}
+
+	ext := h.segmentExt()
+	if !strings.HasPrefix(name, "seg-") || !strings.HasSuffix(name, ext) {
+		http.NotFound(w, r)
+		return
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "seg-"), ext), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	data, ok := h.Segment(seq)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", h.codec.ContentType())
+	// Segments never change once published.
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int((h.cfg.SegmentDuration*time.Duration(h.cfg.Window+hlsRetainExtra)).Seconds())))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+// ---------------------------------------------------------------------------
+// ID3v2.4 tags for packed audio. The first tag of every segment must carry
+// the PRIV timestamp frame defined by the HLS spec so players can place the
+// segment on the timeline; TIT2/TPE1 carry the now-playing information.
+// ---------------------------------------------------------------------------
+
+const hlsTimestampOwner = "com.apple.streaming.transportStreamTimestamp"
+
+// buildHLSID3 returns an ID3 tag describing track. When withTimestamp is set
+// the PRIV timestamp frame is included.
+func buildHLSID3(pts uint64, track *playlist.Track, withTimestamp bool) []byte {
+	var frames bytes.Buffer
+	if withTimestamp {
+		payload := make([]byte, len(hlsTimestampOwner)+1+8)
+		copy(payload, hlsTimestampOwner)
+		binary.BigEndian.PutUint64(payload[len(hlsTimestampOwner)+1:], pts&(1<<33-1))
+		writeID3Frame(&frames, "PRIV", payload)
+	}
+	if track != nil {
+		if track.Title != "" {
+			writeID3Frame(&frames, "TIT2", id3Text(track.Title))
+		}
+		if track.Artist != "" {
+			writeID3Frame(&frames, "TPE1", id3Text(track.Artist))
+		}
+	}
+
+	tag := make([]byte, 10, 10+frames.Len())
+	copy(tag, "ID3")
+	tag[3] = 4 // version 2.4.0
+	putSyncSafe(tag[6:10], frames.Len())
+	return append(tag, frames.Bytes()...)
+}
+
+func writeID3Frame(buf *bytes.Buffer, id string, payload []byte) {
+	var header [10]byte
+	copy(header[:4], id)
+	putSyncSafe(header[4:8], len(payload))
+	buf.Write(header[:])
+	buf.Write(payload)
+}
+
+// id3Text encodes a UTF-8 text frame payload.
+func id3Text(s string) []byte {
+	return append([]byte{0x03}, s...)
+}
+
+func putSyncSafe(b []byte, n int) {
+	b[0] = byte(n>>21) & 0x7F
+	b[1] = byte(n>>14) & 0x7F
+	b[2] = byte(n>>7) & 0x7F
+	b[3] = byte(n) & 0x7F
+}
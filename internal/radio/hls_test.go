@@ -0,0 +1,85 @@
+package radio
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+func TestHLSMarksEncoderRestart(t *testing.T) {
+	b := NewBroadcaster(nil, ffmpeg.NewFakeEncoder("128k", 44100, 2))
+	h, err := NewHLSSegmenter(b, HLSConfig{Mount: DefaultMountPath, SegmentDuration: 50 * time.Millisecond, Window: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Start(ctx)
+
+	b.mu.RLock()
+	m := b.mountUnsafe(DefaultMountPath)
+	b.mu.RUnlock()
+	waitFor(t, 2*time.Second, "the segmenter to subscribe", func() bool {
+		b.mu.RLock()
+		defer b.mu.RUnlock()
+		return len(m.clients) == 1
+	})
+
+	// Two frames (about 52 ms) close one segment.
+	write := func(w *broadcastWriter) {
+		for i := 0; i < 2; i++ {
+			if _, err := w.Write(mp3Frame(false, 0)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	segments := func() int {
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		return len(h.segments)
+	}
+
+	first := &broadcastWriter{broadcaster: b, mount: m, frames: newFrameSplitter(ffmpeg.CodecMP3)}
+	write(first)
+	write(first)
+	waitFor(t, 2*time.Second, "the first segments", func() bool { return segments() == 2 })
+
+	restarted := &broadcastWriter{broadcaster: b, mount: m, frames: newFrameSplitter(ffmpeg.CodecMP3), discontinuity: true}
+	write(restarted)
+	write(restarted)
+	waitFor(t, 2*time.Second, "the segments after the restart", func() bool { return segments() == 4 })
+
+	lines := strings.Split(string(h.Playlist()), "\n")
+	var order []string
+	for _, l := range lines {
+		if strings.HasPrefix(l, "seg-") || l == "#EXT-X-DISCONTINUITY" {
+			order = append(order, l)
+		}
+	}
+	want := []string{"seg-0.mp3", "seg-1.mp3", "#EXT-X-DISCONTINUITY", "seg-2.mp3", "seg-3.mp3"}
+	if strings.Join(order, " ") != strings.Join(want, " ") {
+		t.Fatalf("playlist order %v, want %v", order, want)
+	}
+	if strings.Contains(string(h.Playlist()), "#EXT-X-DISCONTINUITY-SEQUENCE") {
+		t.Fatal("discontinuity sequence written while the discontinuity is still listed")
+	}
+}
+
+func TestHLSDiscontinuitySequence(t *testing.T) {
+	h := &HLSSegmenter{cfg: HLSConfig{SegmentDuration: time.Second, Window: 2}, codec: ffmpeg.CodecMP3}
+	h.segments = []*hlsSegment{
+		{seq: 7, duration: time.Second, discSeq: 1},
+		{seq: 8, duration: time.Second, discontinuity: true, discSeq: 2},
+	}
+	pl := string(h.Playlist())
+	if !strings.Contains(pl, "#EXT-X-DISCONTINUITY-SEQUENCE:1\n") {
+		t.Fatalf("missing discontinuity sequence in\n%s", pl)
+	}
+	if strings.Count(pl, "#EXT-X-DISCONTINUITY\n") != 1 {
+		t.Fatalf("want one discontinuity tag in\n%s", pl)
+	}
+}
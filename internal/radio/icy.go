@@ -0,0 +1,112 @@
+package radio
+
+import (
+	"io"
+	"strings"
+
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// icyMetaInt is the number of audio bytes sent between two metadata blocks
+// for clients that request in-band metadata. 16000 matches the Icecast
+// default, which every common player handles.
+const icyMetaInt = 16000
+
+// icyMaxMetaLen is the largest metadata payload the one-byte length prefix
+// can describe (255 * 16 bytes).
+const icyMaxMetaLen = 255 * 16
+
+// formatStreamTitle renders a track as the "Artist - Title" string used in
+// StreamTitle. Falls back to the bare title when the artist is unknown.
+func formatStreamTitle(t *playlist.Track) string {
+	if t == nil {
+		return ""
+	}
+	if t.Artist == "" {
+		return t.Title
+	}
+	if t.Title == "" {
+		return t.Artist
+	}
+	return t.Artist + " - " + t.Title
+}
+
+// buildICYMetadata encodes a StreamTitle into an ICY metadata block: one
+// length byte (in 16-byte units) followed by the NUL-padded payload.
+func buildICYMetadata(title string) []byte {
+	// A "';" sequence would terminate the value early in most parsers.
+	title = strings.ReplaceAll(title, "';", "'")
+	title = strings.ReplaceAll(title, "\x00", "")
+
+	payload := "StreamTitle='" + title + "';"
+	if len(payload) > icyMaxMetaLen {
+		// Trim the title rather than the framing so the block stays valid.
+		over := len(payload) - icyMaxMetaLen
+		title = strings.ToValidUTF8(title[:len(title)-over], "")
+		payload = "StreamTitle='" + title + "';"
+	}
+
+	blocks := (len(payload) + 15) / 16
+	buf := make([]byte, 1+blocks*16)
+	buf[0] = byte(blocks)
+	copy(buf[1:], payload)
+	return buf
+}
+
+// icyWriter interleaves ICY metadata blocks into an audio byte stream every
+// metaInt bytes. The title is looked up at each boundary so track changes
+// reach the client within one interval. Unchanged titles are sent as an
+// empty (zero-length) block, as Icecast does.
+type icyWriter struct {
+	w         io.Writer
+	metaInt   int
+	remaining int
+	title     func() string
+	lastTitle string
+	sentTitle bool
+}
+
+func newICYWriter(w io.Writer, metaInt int, title func() string) *icyWriter {
+	return &icyWriter{
+		w:         w,
+		metaInt:   metaInt,
+		remaining: metaInt,
+		title:     title,
+	}
+}
+
+func (iw *icyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > iw.remaining {
+			n = iw.remaining
+		}
+		if _, err := iw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+		iw.remaining -= n
+
+		if iw.remaining == 0 {
+			if err := iw.writeMetadata(); err != nil {
+				return written, err
+			}
+			iw.remaining = iw.metaInt
+		}
+	}
+	return written, nil
+}
+
+func (iw *icyWriter) writeMetadata() error {
+	title := iw.title()
+	if iw.sentTitle && title == iw.lastTitle {
+		_, err := iw.w.Write([]byte{0})
+		return err
+	}
+	iw.lastTitle = title
+	iw.sentTitle = true
+	_, err := iw.w.Write(buildICYMetadata(title))
+	return err
+}
@@ -0,0 +1,151 @@
+package radio
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+)
+
+// JingleRules decides when a station ID is inserted between two tracks. Any
+// rule that is due triggers a jingle; zero values disable a rule.
+type JingleRules struct {
+	// EveryTracks inserts a jingle after this many tracks.
+	EveryTracks int
+	// Every inserts a jingle once this much time has passed since the last
+	// one.
+	Every time.Duration
+	// TopOfHour inserts a jingle at the first track change of every hour.
+	TopOfHour bool
+}
+
+func (r JingleRules) enabled() bool {
+	return r.EveryTracks > 0 || r.Every > 0 || r.TopOfHour
+}
+
+// LoadJingles reads every supported audio file directly inside dir. Jingles
+// are kept apart from the library and never appear in playlists.
+func LoadJingles(dir string) ([]*playlist.Track, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jingle directory %q: %w", dir, err)
+	}
+	var jingles []*playlist.Track
+	for _, e := range entries {
+		if e.IsDir() || !playlist.IsSupportedFormat(filepath.Ext(e.Name())) {
+			continue
+		}
+		t, err := playlist.NewTrackFromFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			slog.Warn("Skipping unreadable jingle", "file", e.Name(), "error", err)
+			continue
+		}
+		t.Jingle = true
+		jingles = append(jingles, t)
+	}
+	sort.Slice(jingles, func(i, j int) bool { return jingles[i].FilePath < jingles[j].FilePath })
+	return jingles, nil
+}
+
+// jingleState is the pool of station IDs and the bookkeeping for the rules.
+type jingleState struct {
+	mu    sync.Mutex
+	pool  []*playlist.Track
+	order []int // shuffled indices into pool still to be played
+	rules JingleRules
+
+	tracksSince int       // tracks played since the last jingle
+	lastAt      time.Time // when the last jingle started, zero before any
+	lastHour    string    // hour of the previous track change
+}
+
+// SetJingles configures the station-ID pool and the rules that insert it.
+func (b *Broadcaster) SetJingles(pool []*playlist.Track, rules JingleRules) {
+	b.jingles.mu.Lock()
+	defer b.jingles.mu.Unlock()
+	b.jingles.pool = pool
+	b.jingles.order = nil
+	b.jingles.rules = rules
+}
+
+// dueJingle is called at every track change. It returns the jingle to play
+// next if a rule is due, or nil to continue with the playlist.
+func (b *Broadcaster) dueJingle() *playlist.Track {
+	now := b.now()
+	hour := now.In(b.location()).Format("2006-01-02T15")
+
+	j := &b.jingles
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pool) == 0 || !j.rules.enabled() {
+		return nil
+	}
+	if j.lastAt.IsZero() {
+		// Start the timers with the broadcast rather than opening with a
+		// jingle.
+		j.lastAt = now
+		j.lastHour = hour
+	}
+
+	var reason string
+	switch {
+	case j.rules.TopOfHour && hour != j.lastHour:
+		reason = "top_of_hour"
+	case j.rules.EveryTracks > 0 && j.tracksSince >= j.rules.EveryTracks:
+		reason = "every_tracks"
+	case j.rules.Every > 0 && now.Sub(j.lastAt) >= j.rules.Every:
+		reason = "every_minutes"
+	}
+	j.lastHour = hour
+	if reason == "" {
+		j.tracksSince++
+		return nil
+	}
+
+	if len(j.order) == 0 {
+		j.order = rand.Perm(len(j.pool))
+	}
+	jingle := j.pool[j.order[0]]
+	j.order = j.order[1:]
+	j.tracksSince = 0
+	j.lastAt = now
+	slog.Info("Inserting jingle", "file", filepath.Base(jingle.FilePath), "rule", reason)
+	return jingle
+}
+
+// CurrentJingle describes the station ID on air, or returns nil while a
+// regular track is playing. The song title is left untouched during a
+// jingle.
+func (b *Broadcaster) CurrentJingle() *service.JingleInfo {
+	t, _ := b.currentJingle.Load().(*playlist.Track)
+	if t == nil {
+		return nil
+	}
+	return &service.JingleInfo{
+		Title: t.Title,
+		File:  filepath.Base(t.FilePath),
+	}
+}
+
+// now returns the current time from the master playlist's clock.
+func (b *Broadcaster) now() time.Time {
+	if master := b.master(); master != nil {
+		return master.Now()
+	}
+	return time.Now()
+}
+
+// location returns the station timezone.
+func (b *Broadcaster) location() *time.Location {
+	if master := b.master(); master != nil {
+		return master.Location()
+	}
+	return time.UTC
+}
@@ -0,0 +1,311 @@
+package radio
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/events"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+)
+
+// ErrLiveBusy is returned when a DJ tries to go live while another source
+// is already connected.
+var ErrLiveBusy = errors.New("mountpoint in use")
+
+// LiveInput is an encoded stream from a DJ that takes over the broadcast
+// while it is connected.
+type LiveInput struct {
+	mount     string
+	format    string // ffmpeg demuxer hint, empty to probe
+	name      string // ice-name announced by the source client
+	r         io.Reader
+	startedAt time.Time
+
+	done    chan struct{} // closed once the broadcaster is finished with r
+	started bool          // guarded by Broadcaster.mu
+}
+
+// Done is closed when the broadcaster has stopped reading the input.
+func (li *LiveInput) Done() <-chan struct{} {
+	return li.done
+}
+
+// BeginLive registers a DJ stream and interrupts the automation so it goes
+// on air. Only one live input can be active at a time.
+func (b *Broadcaster) BeginLive(mount, format, name string, r io.Reader) (*LiveInput, error) {
+	b.mu.Lock()
+	if b.live != nil {
+		b.mu.Unlock()
+		return nil, ErrLiveBusy
+	}
+	li := &LiveInput{
+		mount:     mount,
+		format:    format,
+		name:      name,
+		r:         r,
+		startedAt: time.Now().UTC(),
+		done:      make(chan struct{}),
+	}
+	b.live = li
+	b.mu.Unlock()
+
+	// Cut the current track (or fallback) so the DJ goes on air at once.
+	b.Skip()
+	return li, nil
+}
+
+// takeLive returns the pending live input, if any, and marks it as on air.
+func (b *Broadcaster) takeLive() *LiveInput {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.live == nil || b.live.started {
+		return nil
+	}
+	b.live.started = true
+	return b.live
+}
+
+// endLive releases the live input so the next DJ can connect.
+func (b *Broadcaster) endLive(li *LiveInput) {
+	b.mu.Lock()
+	if b.live == li {
+		b.live = nil
+	}
+	b.mu.Unlock()
+	close(li.done)
+}
+
+// playLive feeds the DJ's stream into the mixer until the source
+// disconnects, then hands control back to the automation.
+func (b *Broadcaster) playLive(ctx context.Context, mixer *crossfader, li *LiveInput) error {
+	defer b.endLive(li)
+
+	slog.Info("Live source on air", "mount", li.mount, "name", li.name)
+	b.liftPause("live source on air")
+	b.currentTrack.Store("")
+	info := &playlist.Track{Title: li.displayName(), FilePath: li.mount}
+	b.currentInfo.Store(info)
+
+	mixer.StartTrack(0)
+	startedAt := b.startProgress(info, mixer, 0)
+	b.publish(events.TypeTrackStarted, events.TrackStarted{Track: info, Live: true, StartedAt: startedAt})
+	err := b.encoder.DecodeStream(ctx, li.format, li.r, &firstWriteHook{w: mixer, hook: b.endFallback})
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		slog.Warn("Live source decoding ended with error", "mount", li.mount, "error", err)
+	}
+
+	// Skips requested while the DJ was on air must not hit the first track
+	// after the hand-back.
+	select {
+	case <-b.skipCh:
+	default:
+	}
+
+	slog.Info("Live source ended, returning to automation", "mount", li.mount, "duration", time.Since(li.startedAt).Round(time.Second))
+	return mixer.EndTrack()
+}
+
+// displayName is the now-playing title used until the DJ sends metadata.
+func (li *LiveInput) displayName() string {
+	if li.name != "" {
+		return li.name
+	}
+	return "Live"
+}
+
+// UpdateLiveMetadata sets the now-playing information while a DJ is on air.
+// song follows the Icecast convention "Artist - Title".
+func (b *Broadcaster) UpdateLiveMetadata(song string) error {
+	b.mu.RLock()
+	li := b.live
+	b.mu.RUnlock()
+	if li == nil {
+		return errors.New("no live source connected")
+	}
+
+	track := &playlist.Track{Title: strings.TrimSpace(song), FilePath: li.mount}
+	if artist, title, ok := strings.Cut(song, " - "); ok {
+		track.Artist = strings.TrimSpace(artist)
+		track.Title = strings.TrimSpace(title)
+	}
+	if track.Title == "" && track.Artist == "" {
+		track.Title = li.displayName()
+	}
+	b.currentInfo.Store(track)
+	startedAt := b.restartProgress(track)
+	b.publish(events.TypeTrackStarted, events.TrackStarted{Track: track, Live: true, StartedAt: startedAt})
+	return nil
+}
+
+// LiveStatus describes the connected DJ, or returns nil during automation.
+func (b *Broadcaster) LiveStatus() *service.LiveInfo {
+	b.mu.RLock()
+	li := b.live
+	b.mu.RUnlock()
+	if li == nil {
+		return nil
+	}
+	return &service.LiveInfo{
+		Mount:     li.mount,
+		Name:      li.name,
+		StartedAt: li.startedAt,
+		OnAir:     li.started,
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SourceHandler accepts Icecast-style source connections (the legacy SOURCE
+// method and the HTTP PUT used by Icecast 2.4+), as sent by BUTT, Mixxx and
+// other source clients. The request body is the DJ's encoded stream.
+// ---------------------------------------------------------------------------
+
+type SourceHandler struct {
+	broadcaster *Broadcaster
+	mount       string
+	username    string
+	password    string
+
+	mu sync.Mutex // serialises metadata updates from the admin endpoint
+}
+
+func NewSourceHandler(broadcaster *Broadcaster, mount, username, password string) *SourceHandler {
+	return &SourceHandler{
+		broadcaster: broadcaster,
+		mount:       mount,
+		username:    username,
+		password:    password,
+	}
+}
+
+// authorised checks the HTTP basic credentials of a source client.
+func (h *SourceHandler) authorised(r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(h.username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(h.password)) == 1
+	return userOK && passOK
+}
+
+func (h *SourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientIP := r.RemoteAddr
+	if !h.authorised(r) {
+		slog.Warn("Live source rejected", "reason", "bad_credentials", "ip", clientIP)
+		w.Header().Set("WWW-Authenticate", `Basic realm="Icecast2 Server"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	// SOURCE requests (and PUTs from many clients) carry neither a length
+	// nor chunked framing, so net/http would see an empty body. Take over
+	// the connection and read the raw stream instead.
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		slog.Error("Failed to hijack source connection", "error", err)
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Time{})
+
+	// The hijacked reader starts right after the headers; undo any framing
+	// the client chose.
+	var body io.Reader = rw.Reader
+	switch {
+	case len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked":
+		body = httputil.NewChunkedReader(rw.Reader)
+	case r.ContentLength > 0:
+		body = io.LimitReader(rw.Reader, r.ContentLength)
+	}
+
+	li, err := h.broadcaster.BeginLive(h.mount, sourceFormat(r.Header.Get("Content-Type")), r.Header.Get("Ice-Name"), body)
+	if err != nil {
+		slog.Warn("Live source rejected", "reason", err.Error(), "ip", clientIP)
+		_, _ = rw.WriteString("HTTP/1.0 403 Forbidden\r\nContent-Type: text/plain\r\n\r\nMountpoint in use\r\n")
+		_ = rw.Flush()
+		return
+	}
+	slog.Info("Live source connected",
+		"ip", clientIP,
+		"mount", h.mount,
+		"method", r.Method,
+		"content_type", r.Header.Get("Content-Type"),
+		"name", r.Header.Get("Ice-Name"),
+	)
+
+	_, _ = rw.WriteString("HTTP/1.0 200 OK\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		// The client is already gone; closing makes the decoder see EOF.
+		conn.Close()
+		<-li.Done()
+		return
+	}
+
+	// Close the connection as soon as the broadcaster lets go of it so a
+	// reader blocked in ffmpeg's stdin copier returns.
+	go func() {
+		<-li.Done()
+		conn.Close()
+	}()
+	<-li.Done()
+	slog.Info("Live source disconnected", "ip", clientIP, "mount", h.mount)
+}
+
+// Metadata handles the Icecast admin call source clients use to announce the
+// current song: GET /admin/metadata?mode=updinfo&mount=/live&song=...
+func (h *SourceHandler) Metadata(w http.ResponseWriter, r *http.Request) {
+	if !h.authorised(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Icecast2 Server"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("mode") != "updinfo" || q.Get("mount") != h.mount {
+		http.Error(w, "Unsupported request", http.StatusBadRequest)
+		return
+	}
+
+	h.mu.Lock()
+	err := h.broadcaster.UpdateLiveMetadata(q.Get("song"))
+	h.mu.Unlock()
+	if err != nil {
+		http.Error(w, "Source does not exist", http.StatusBadRequest)
+		return
+	}
+	slog.Info("Live metadata updated", "mount", h.mount, "song", q.Get("song"))
+
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = io.WriteString(w, "<?xml version=\"1.0\"?>\n<iceresponse><message>Metadata update successful</message><return>1</return></iceresponse>\n")
+}
+
+// sourceFormat maps a source client's Content-Type to an ffmpeg demuxer.
+func sourceFormat(contentType string) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/ogg", "application/ogg", "audio/opus":
+		return "ogg"
+	case "audio/aac", "audio/aacp":
+		return "aac"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,223 @@
+package radio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+// DefaultMountPath is the mount every station serves. It always exists and
+// encodes MP3 at the configured BITRATE unless MOUNTS overrides it.
+const DefaultMountPath = "/stream"
+
+// MountConfig describes one listener endpoint and how its audio is encoded.
+// Every mount plays the same programme; only the encoding differs.
+type MountConfig struct {
+	Path   string
+	Format ffmpeg.OutputFormat
+}
+
+// ParseMounts parses a MOUNTS specification of the form
+//
+//	/stream.opus:opus:64k,/stream-64.mp3:mp3:64k,/stream.aac:aac:96k
+//
+// The default /stream mount (MP3 at defaultBitrate) is always included first;
+// listing /stream explicitly replaces its settings.
+func ParseMounts(spec, defaultBitrate string) ([]MountConfig, error) {
+	mounts := []MountConfig{{
+		Path:   DefaultMountPath,
+		Format: ffmpeg.OutputFormat{Codec: ffmpeg.CodecMP3, Bitrate: defaultBitrate},
+	}}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid mount %q: expected path:codec:bitrate", entry)
+		}
+		path := strings.TrimSpace(parts[0])
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "/api/") || strings.ContainsAny(path, " ?#*:") {
+			return nil, fmt.Errorf("invalid mount path %q", path)
+		}
+		codec, err := ffmpeg.ParseCodec(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid mount %q: %w", entry, err)
+		}
+		mc := MountConfig{
+			Path:   path,
+			Format: ffmpeg.OutputFormat{Codec: codec, Bitrate: strings.TrimSpace(parts[2])},
+		}
+
+		replaced := false
+		for i := range mounts {
+			if mounts[i].Path == path {
+				if i > 0 {
+					return nil, fmt.Errorf("duplicate mount path %q", path)
+				}
+				mounts[i] = mc
+				replaced = true
+			}
+		}
+		if !replaced {
+			mounts = append(mounts, mc)
+		}
+	}
+	return mounts, nil
+}
+
+// mount is the runtime state of a MountConfig: its own subscriber set plus
+// what new subscribers need before joining mid-stream (stream header and
+// burst buffer). The clients map is guarded by Broadcaster.mu; the rest by
+// mu.
+type mount struct {
+	cfg     MountConfig
+	clients map[uint64]*clientSub
+
+	// Ogg streams cannot be decoded without their header pages (OpusHead and
+	// OpusTags), which the encoder only emits once at start-up. They are
+	// captured here and replayed to every new subscriber.
+	mu         sync.Mutex
+	header     []byte
+	headerDone bool
+	pending    []byte
+
+	// burst holds the most recent whole frames, sent to new subscribers so
+	// their player can fill its buffer at once instead of in real time.
+	burst    []audioFrame
+	burstDur time.Duration
+}
+
+func newMount(cfg MountConfig) *mount {
+	return &mount{
+		cfg:     cfg,
+		clients: make(map[uint64]*clientSub),
+	}
+}
+
+// listenersUnsafe counts the mount's non-internal subscribers. The caller
+// must hold Broadcaster.mu.
+func (m *mount) listenersUnsafe() int {
+	n := 0
+	for _, sub := range m.clients {
+		if !sub.internal {
+			n++
+		}
+	}
+	return n
+}
+
+// resetHeader forgets the captured header; called before a new encoder
+// process starts producing a fresh stream.
+func (m *mount) resetHeader() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.header = nil
+	m.pending = nil
+	m.headerDone = m.cfg.Format.Codec != ffmpeg.CodecOpus
+}
+
+// captureHeader inspects encoder output until the first audio page and keeps
+// the Ogg header pages (granule position 0) seen before it.
+func (m *mount) captureHeader(p []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.headerDone {
+		return
+	}
+	m.pending = append(m.pending, p...)
+	for {
+		if len(m.pending) >= 4 && string(m.pending[:4]) != "OggS" {
+			// Not at a page boundary; nothing sensible to capture.
+			m.headerDone = true
+			m.pending = nil
+			return
+		}
+		size, granule, ok := oggPage(m.pending)
+		if !ok {
+			return
+		}
+		if granule != 0 {
+			m.headerDone = true
+			m.pending = nil
+			return
+		}
+		m.header = append(m.header, m.pending[:size]...)
+		m.pending = m.pending[size:]
+	}
+}
+
+// streamHeader returns a copy of the captured header, or nil if the codec
+// needs none.
+func (m *mount) streamHeader() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.header) == 0 {
+		return nil
+	}
+	h := make([]byte, len(m.header))
+	copy(h, m.header)
+	return h
+}
+
+// pushBurst appends frames to the burst buffer and drops the oldest ones
+// beyond limit. A zero limit disables the buffer.
+func (m *mount) pushBurst(frames []audioFrame, limit time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit <= 0 {
+		m.burst = nil
+		m.burstDur = 0
+		return
+	}
+	for _, f := range frames {
+		m.burst = append(m.burst, f)
+		m.burstDur += f.duration
+	}
+	drop := 0
+	for drop < len(m.burst) && m.burstDur-m.burst[drop].duration >= limit {
+		m.burstDur -= m.burst[drop].duration
+		drop++
+	}
+	if drop > 0 {
+		m.burst = append(m.burst[:0], m.burst[drop:]...)
+	}
+}
+
+// burstSnapshot returns the buffered frames as one chunk starting on a frame
+// boundary. Its data is nil if the buffer is empty.
+func (m *mount) burstSnapshot() streamChunk {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.burst) == 0 {
+		return streamChunk{}
+	}
+	return chunkFromFrames(m.burst)
+}
+
+// oggPage parses the Ogg page at the start of buf and returns its total size
+// and granule position. ok is false until the whole page is buffered.
+func oggPage(buf []byte) (size int, granule uint64, ok bool) {
+	const headerLen = 27
+	if len(buf) < headerLen || string(buf[:4]) != "OggS" {
+		return 0, 0, false
+	}
+	segments := int(buf[26])
+	if len(buf) < headerLen+segments {
+		return 0, 0, false
+	}
+	size = headerLen + segments
+	for _, l := range buf[headerLen : headerLen+segments] {
+		size += int(l)
+	}
+	if len(buf) < size {
+		return 0, 0, false
+	}
+	return size, binary.LittleEndian.Uint64(buf[6:14]), true
+}
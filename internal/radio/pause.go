@@ -0,0 +1,225 @@
+package radio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+)
+
+// pauseState is the DJ's pause switch. While paused, the track on air is
+// held where it is and the pause source keeps listeners connected.
+type pauseState struct {
+	mu      sync.Mutex
+	src     FallbackSource
+	since   time.Time     // zero while playing
+	resumed chan struct{} // closed by Resume
+}
+
+// SetPauseSource configures what is played while the broadcast is paused:
+// silence, a tone or a looped "we'll be right back" file.
+func (b *Broadcaster) SetPauseSource(src FallbackSource) {
+	b.pause.mu.Lock()
+	defer b.pause.mu.Unlock()
+	b.pause.src = src
+}
+
+// Pause freezes the track on air at its current position. It takes effect
+// within one decoder write. Only playlist tracks can be paused: it is
+// refused while the fallback source is on air or a live DJ is connected.
+func (b *Broadcaster) Pause() error {
+	if b.FallbackReason() != "" {
+		return errors.New("cannot pause while the fallback source is on air")
+	}
+	if b.LiveStatus() != nil {
+		return errors.New("cannot pause while a live source is connected")
+	}
+
+	p := &b.pause
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.since.IsZero() {
+		return errors.New("broadcast is already paused")
+	}
+	p.since = time.Now().UTC()
+	p.resumed = make(chan struct{})
+	slog.Info("Broadcast paused")
+	return nil
+}
+
+// Resume continues the paused track from exactly where it stopped.
+func (b *Broadcaster) Resume() error {
+	p := &b.pause
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.since.IsZero() {
+		return errors.New("broadcast is not paused")
+	}
+	slog.Info("Broadcast resumed", "paused_for", time.Since(p.since).Round(time.Second))
+	p.since = time.Time{}
+	close(p.resumed)
+	return nil
+}
+
+// liftPause ends a pause that can no longer hold a track because the
+// fallback or a live source is taking over, so the status stays truthful.
+func (b *Broadcaster) liftPause(reason string) {
+	if b.Resume() == nil {
+		slog.Warn("Pause lifted", "reason", reason)
+	}
+}
+
+// PauseStatus describes the pause, or returns nil while playing.
+func (b *Broadcaster) PauseStatus() *service.PauseInfo {
+	p := &b.pause
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.since.IsZero() {
+		return nil
+	}
+	return &service.PauseInfo{
+		Since:    p.since,
+		Duration: time.Since(p.since).Seconds(),
+	}
+}
+
+// pauseFade is how long the track and the pause source take to fade into
+// and out of each other.
+const pauseFade = 50 * time.Millisecond
+
+// pauseGate sits between a track's decoder and the mixer. While the
+// broadcast is paused it holds back the decoder, which leaves the track and
+// the mixer's held-back audio untouched, and plays the pause source straight
+// to the paced output instead.
+type pauseGate struct {
+	ctx         context.Context // the track's context; cancelled on skip
+	broadcaster *Broadcaster
+	mixer       *crossfader
+	out         io.Writer // the paced output below the mixer
+}
+
+func (g *pauseGate) Write(p []byte) (int, error) {
+	if err := g.broadcaster.waitWhilePaused(g.ctx, g.mixer, g.out); err != nil {
+		return 0, err
+	}
+	return g.mixer.Write(p)
+}
+
+// waitWhilePaused returns at once during playout. While paused, it fades
+// the track out, plays the pause source to out until Resume is called or
+// ctx is cancelled, and lets the mixer fade the track back in.
+func (b *Broadcaster) waitWhilePaused(ctx context.Context, mixer *crossfader, out io.Writer) error {
+	p := &b.pause
+	p.mu.Lock()
+	paused, resumed, src := !p.since.IsZero(), p.resumed, p.src
+	p.mu.Unlock()
+	if !paused {
+		return nil
+	}
+
+	if err := mixer.FadeOut(); err != nil {
+		return err
+	}
+
+	fillCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-resumed:
+			cancel()
+		case <-fillCtx.Done():
+		}
+	}()
+
+	fadeBytes := int(pauseFade.Seconds() * float64(b.encoder.BytesPerSecond()))
+	fw := newFadeWriter(out, b.encoder.FrameBytes(), fadeBytes)
+
+	if src.Kind == FallbackFile {
+		for fillCtx.Err() == nil {
+			if err := b.encoder.Decode(fillCtx, src.File, ffmpeg.DecodeOptions{}, fw); err != nil {
+				if fillCtx.Err() == nil {
+					slog.Error("Pause file failed, using silence", "file", src.File, "error", err)
+					src.Kind = FallbackSilence
+				}
+				break
+			}
+		}
+	}
+
+	gen := newSignalGenerator(src.Kind, b.encoder.SampleRateHz(), b.encoder.ChannelCount())
+	chunk := make([]byte, b.encoder.BytesPerSecond()/20) // 50 ms
+	chunk = chunk[:len(chunk)-len(chunk)%b.encoder.FrameBytes()]
+	for fillCtx.Err() == nil {
+		gen.fill(chunk)
+		if _, err := fw.Write(chunk); err != nil {
+			return err
+		}
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// fadeWriter fades the PCM written through it in over its first fade bytes
+// and, on Close, out over the last fade bytes, which it holds back until
+// then.
+type fadeWriter struct {
+	out       io.Writer
+	frameSize int
+	fade      int
+	written   int // bytes passed to out so far
+	hold      []byte
+}
+
+func newFadeWriter(out io.Writer, frameSize, fade int) *fadeWriter {
+	return &fadeWriter{out: out, frameSize: frameSize, fade: fade - fade%frameSize}
+}
+
+func (fw *fadeWriter) Write(p []byte) (int, error) {
+	fw.hold = append(fw.hold, p...)
+	n := len(fw.hold) - fw.fade
+	n -= n % fw.frameSize
+	if n <= 0 {
+		return len(p), nil
+	}
+	if err := fw.emit(fw.hold[:n]); err != nil {
+		return 0, err
+	}
+	fw.hold = append(fw.hold[:0], fw.hold[n:]...)
+	return len(p), nil
+}
+
+// Close fades out and writes the held-back audio.
+func (fw *fadeWriter) Close() error {
+	fw.hold = fw.hold[:len(fw.hold)-len(fw.hold)%fw.frameSize]
+	frames := len(fw.hold) / fw.frameSize
+	applyGain(fw.hold, fw.frameSize, func(frame int) float64 {
+		return 1 - float64(frame+1)/float64(frames)
+	})
+	err := fw.emit(fw.hold)
+	fw.hold = fw.hold[:0]
+	return err
+}
+
+// emit writes pcm to out, fading it in if it falls within the first fade
+// bytes.
+func (fw *fadeWriter) emit(pcm []byte) error {
+	if len(pcm) == 0 {
+		return nil
+	}
+	if fw.written < fw.fade {
+		start, frames := fw.written/fw.frameSize, fw.fade/fw.frameSize
+		applyGain(pcm, fw.frameSize, func(frame int) float64 {
+			return min(1, float64(start+frame+1)/float64(frames))
+		})
+	}
+	fw.written += len(pcm)
+	_, err := fw.out.Write(pcm)
+	return err
+}
@@ -0,0 +1,121 @@
+package radio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+// constantPCM returns frames of mono s16le PCM, every sample v.
+func constantPCM(frames int, v int16) []byte {
+	pcm := make([]byte, frames*pcmSampleBytes)
+	for i := 0; i < frames; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*pcmSampleBytes:], uint16(v))
+	}
+	return pcm
+}
+
+func sampleAt(pcm []byte, frame int) int16 {
+	return int16(binary.LittleEndian.Uint16(pcm[frame*pcmSampleBytes:]))
+}
+
+func TestPauseRefusedOffPlaylist(t *testing.T) {
+	b := NewBroadcaster(nil, ffmpeg.NewFakeEncoder("128k", 44100, 2))
+
+	b.fallbackReason.Store("no playable track in the active playlist")
+	if err := b.Pause(); err == nil {
+		t.Fatal("pause accepted while the fallback is on air")
+	}
+	b.fallbackReason.Store("")
+
+	b.live = &LiveInput{mount: "/live"}
+	if err := b.Pause(); err == nil {
+		t.Fatal("pause accepted while a live source is connected")
+	}
+	b.live = nil
+
+	if err := b.Pause(); err != nil {
+		t.Fatalf("pause refused during playout: %v", err)
+	}
+	if b.PauseStatus() == nil {
+		t.Fatal("status does not report the pause")
+	}
+
+	b.liftPause("fallback source on air")
+	if b.PauseStatus() != nil {
+		t.Fatal("pause still reported after the fallback took over")
+	}
+}
+
+func TestFadeWriter(t *testing.T) {
+	var out bytes.Buffer
+	fw := newFadeWriter(&out, pcmSampleBytes, 100*pcmSampleBytes)
+	for i := 0; i < 4; i++ {
+		if _, err := fw.Write(constantPCM(100, 10000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.Len() != 300*pcmSampleBytes {
+		t.Fatalf("wrote %d bytes before Close, want the fade held back", out.Len())
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pcm := out.Bytes()
+	if got := sampleAt(pcm, 0); got > 200 {
+		t.Fatalf("first sample %d, want it faded in", got)
+	}
+	if got := sampleAt(pcm, 49); got < 4000 || got > 6000 {
+		t.Fatalf("sample halfway through the fade-in %d, want about half", got)
+	}
+	if got := sampleAt(pcm, 200); got != 10000 {
+		t.Fatalf("sample after the fade-in %d, want full level", got)
+	}
+	if got := sampleAt(pcm, 399); got != 0 {
+		t.Fatalf("last sample %d, want it faded out", got)
+	}
+}
+
+func TestCrossfaderPauseFade(t *testing.T) {
+	var out bytes.Buffer
+	cf := newCrossfader(&out, pcmSampleBytes, 100*pcmSampleBytes)
+	cf.StartTrack(0)
+
+	if _, err := cf.Write(constantPCM(300, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.FadeOut(); err != nil {
+		t.Fatal(err)
+	}
+	pcm := out.Bytes()
+	if len(pcm) != 300*pcmSampleBytes {
+		t.Fatalf("aired %d bytes, want all but the faded hold", len(pcm))
+	}
+	if got := sampleAt(pcm, 199); got != 10000 {
+		t.Fatalf("sample before the fade-out %d, want full level", got)
+	}
+	if got := sampleAt(pcm, 299); got != 0 {
+		t.Fatalf("last sample before the pause %d, want silence", got)
+	}
+	if got := cf.onAir.Load(); got != int64(len(pcm)) {
+		t.Fatalf("on-air position %d, want %d", got, len(pcm))
+	}
+
+	out.Reset()
+	if _, err := cf.Write(constantPCM(300, 10000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.EndTrack(); err != nil {
+		t.Fatal(err)
+	}
+	pcm = out.Bytes()
+	if got := sampleAt(pcm, 0); got > 200 {
+		t.Fatalf("first sample after the pause %d, want it faded in", got)
+	}
+	if got := sampleAt(pcm, 150); got != 10000 {
+		t.Fatalf("sample after the fade-in %d, want full level", got)
+	}
+}
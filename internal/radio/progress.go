@@ -0,0 +1,87 @@
+package radio
+
+import (
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
+)
+
+// trackProgress follows how far listeners are into the track on air. The
+// position is taken from the audio the mixer has handed to the encoders, so
+// it stands still while the broadcast is paused, and is shifted back by the
+// audio listeners hold in their buffers: the pacing lead and the burst sent
+// on connect.
+type trackProgress struct {
+	track          *playlist.Track
+	mixer          *crossfader
+	base           int64         // mixer position the track started at
+	offset         time.Duration // position decoding started from
+	startedAt      time.Time     // when listeners started hearing it
+	latency        time.Duration
+	bytesPerSecond int
+}
+
+// startProgress begins following a track that has just been handed to the
+// mixer, offset into the file. It returns when listeners will hear it.
+func (b *Broadcaster) startProgress(track *playlist.Track, mixer *crossfader, offset time.Duration) time.Time {
+	b.mu.RLock()
+	latency := b.burst + paceLead
+	b.mu.RUnlock()
+
+	p := &trackProgress{
+		track:          track,
+		mixer:          mixer,
+		base:           mixer.onAir.Load(),
+		offset:         offset,
+		startedAt:      time.Now().UTC().Add(latency),
+		latency:        latency,
+		bytesPerSecond: b.encoder.BytesPerSecond(),
+	}
+	b.progress.Store(p)
+	return p.startedAt
+}
+
+// restartProgress starts following a new song within the same audio, such
+// as new metadata from a live DJ. It does nothing if no track is followed.
+func (b *Broadcaster) restartProgress(track *playlist.Track) time.Time {
+	p, _ := b.progress.Load().(*trackProgress)
+	if p == nil {
+		return time.Time{}
+	}
+	return b.startProgress(track, p.mixer, 0)
+}
+
+// clearProgress stops following the track, e.g. while a jingle or the
+// fallback is on air.
+func (b *Broadcaster) clearProgress() {
+	b.progress.Store((*trackProgress)(nil))
+}
+
+// Progress reports how far listeners are into the track on air, or returns
+// nil when no track is being followed.
+func (b *Broadcaster) Progress() *service.TrackProgress {
+	p, _ := b.progress.Load().(*trackProgress)
+	if p == nil {
+		return nil
+	}
+
+	sent := time.Duration(float64(p.mixer.onAir.Load()-p.base) / float64(p.bytesPerSecond) * float64(time.Second))
+	heard := sent - p.latency
+	if heard < 0 {
+		heard = 0
+	}
+	elapsed := p.offset + heard
+
+	info := &service.TrackProgress{StartedAt: p.startedAt}
+	if p.track.Duration > 0 {
+		duration := time.Duration(p.track.Duration) * time.Second
+		if elapsed > duration {
+			elapsed = duration
+		}
+		remaining := (duration - elapsed).Seconds()
+		info.Remaining = &remaining
+	}
+	info.Elapsed = elapsed.Seconds()
+	return info
+}
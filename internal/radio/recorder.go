@@ -0,0 +1,172 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/archive"
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+)
+
+// RecordRotation selects when the recorder starts a new file.
+type RecordRotation string
+
+const (
+	RotateHourly RecordRotation = "hourly" // on every hour in the station timezone
+	RotateSlot   RecordRotation = "slot"   // whenever the schedule's time slot or day changes
+)
+
+// ParseRecordRotation parses an ARCHIVE_ROTATION value.
+func ParseRecordRotation(s string) (RecordRotation, error) {
+	switch r := RecordRotation(strings.ToLower(strings.TrimSpace(s))); r {
+	case "", RotateHourly:
+		return RotateHourly, nil
+	case RotateSlot:
+		return RotateSlot, nil
+	default:
+		return "", fmt.Errorf("invalid archive rotation %q: must be one of hourly, slot", s)
+	}
+}
+
+// Recorder subscribes to a mount like any listener and writes its encoded
+// output to the archive, rotating files hourly or at schedule slot changes
+// and noting which tracks played in each file.
+type Recorder struct {
+	broadcaster *Broadcaster
+	archive     *archive.Archive
+	mount       string
+	codec       ffmpeg.Codec
+	rotation    RecordRotation
+
+	// Only touched by the Start goroutine.
+	file      *archive.File
+	key       string        // rotation key of the open file
+	offset    time.Duration // audio written to the open file
+	lastTitle string
+}
+
+// NewRecorder validates the mount and returns a recorder for it. Only MP3
+// and AAC mounts can be recorded, since their files can be cut on any frame.
+func NewRecorder(b *Broadcaster, a *archive.Archive, mountPath string, rotation RecordRotation) (*Recorder, error) {
+	b.mu.RLock()
+	m := b.mountUnsafe(mountPath)
+	b.mu.RUnlock()
+	if m == nil {
+		return nil, fmt.Errorf("archive mount %q not found", mountPath)
+	}
+	codec := m.cfg.Format.Codec
+	if codec != ffmpeg.CodecMP3 && codec != ffmpeg.CodecAAC {
+		return nil, fmt.Errorf("archive mount %q uses %s; only mp3 and aac are supported", mountPath, codec)
+	}
+	return &Recorder{
+		broadcaster: b,
+		archive:     a,
+		mount:       mountPath,
+		codec:       codec,
+		rotation:    rotation,
+	}, nil
+}
+
+// Start records until ctx is cancelled.
+func (r *Recorder) Start(ctx context.Context) {
+	sub, err := r.broadcaster.subscribe(r.mount, true)
+	if err != nil {
+		slog.Error("Recorder failed to subscribe", "mount", r.mount, "error", err)
+		return
+	}
+	defer r.broadcaster.Unsubscribe(sub)
+	defer r.closeFile()
+
+	slog.Info("Recorder started", "mount", r.mount, "rotation", r.rotation)
+	r.archive.Prune(time.Now())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			sub.consumed(chunk)
+			r.write(chunk)
+		}
+	}
+}
+
+// write appends chunk to the current file, rotating first if needed. Chunks
+// always hold whole frames, so every file starts on a frame boundary.
+func (r *Recorder) write(chunk streamChunk) {
+	now := time.Now()
+	key := r.rotationKey(now)
+	if key != r.key {
+		r.closeFile()
+		r.key = key
+		r.archive.Prune(now)
+
+		f, err := r.archive.Create(now, r.codec.Extension(), r.mount, r.slot())
+		if err != nil {
+			// Retried at the next rotation rather than on every chunk.
+			slog.Error("Failed to start recording", "error", err)
+			return
+		}
+		r.file = f
+		r.offset = 0
+		r.lastTitle = ""
+		slog.Info("Recording started", "name", f.Name())
+	}
+	if r.file == nil {
+		return
+	}
+
+	if title := r.broadcaster.StreamTitle(); title != r.lastTitle {
+		r.lastTitle = title
+		if info := r.broadcaster.CurrentTrackInfo(); info != nil {
+			r.file.AddTrack(info.Title, info.Artist, now, r.offset)
+		}
+	}
+
+	if _, err := r.file.Write(chunk.data); err != nil {
+		slog.Error("Failed to write recording, stopping until the next rotation", "name", r.file.Name(), "error", err)
+		r.closeFile()
+		return
+	}
+	r.offset += chunk.duration
+}
+
+func (r *Recorder) closeFile() {
+	if r.file == nil {
+		return
+	}
+	if err := r.file.Close(); err != nil {
+		slog.Error("Failed to finish recording", "name", r.file.Name(), "error", err)
+	} else {
+		slog.Info("Recording finished", "name", r.file.Name(), "duration", r.offset.Round(time.Second))
+	}
+	r.file = nil
+}
+
+// rotationKey changes value exactly when a new file should be started.
+// Slot rotation also keys on the schedule day, so a slot that follows one
+// with the same name, or a single all-day slot, still starts a new file
+// every day.
+func (r *Recorder) rotationKey(now time.Time) string {
+	if r.rotation == RotateSlot {
+		if master := r.broadcaster.master(); master != nil {
+			return string(master.ActiveTag()) + "/" + master.ActiveDay().String()
+		}
+		return now.In(r.broadcaster.location()).Format("2006-01-02")
+	}
+	return now.In(r.broadcaster.location()).Format("2006-01-02T15")
+}
+
+// slot returns the schedule's active time tag, if a master playlist is set.
+func (r *Recorder) slot() string {
+	if master := r.broadcaster.master(); master != nil {
+		return string(master.ActiveTag())
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package radio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+func TestSlotRotationStartsNewFileEachDay(t *testing.T) {
+	master := playlist.NewMasterPlaylist()
+	if err := master.SetSlots([]playlist.TimeSlot{{Name: "allday", Start: 0, End: 0}}); err != nil {
+		t.Fatal(err)
+	}
+	clock := playlist.NewFakeClock(time.Date(2026, 3, 2, 23, 59, 0, 0, time.UTC))
+	master.SetClock(clock)
+	master.ResolveActiveTag()
+
+	b := NewBroadcaster(nil, ffmpeg.NewFakeEncoder("128k", 44100, 2))
+	b.SetMasterPlaylist(master)
+	r := &Recorder{broadcaster: b, rotation: RotateSlot}
+
+	before := r.rotationKey(clock.Now())
+	clock.Set(time.Date(2026, 3, 3, 0, 1, 0, 0, time.UTC))
+	master.ResolveActiveTag()
+	after := r.rotationKey(clock.Now())
+
+	if before == after {
+		t.Fatalf("rotation key %q did not change at midnight", before)
+	}
+}
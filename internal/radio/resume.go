@@ -0,0 +1,132 @@
+package radio
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// playCursor follows the regular track on air so its position can be
+// checkpointed and its play logged. The position is taken from the PCM the
+// mixer has flushed, not from what the decoder has produced, because the
+// mixer holds back up to a crossfade's worth of the track; the flushed
+// audio is paced to real time, so that is how far the track has played.
+type playCursor struct {
+	mixer          *crossfader
+	track          *playlist.Track
+	tag            playlist.TimeTag
+	playlistID     int64
+	playlistName   string
+	checksum       string
+	startedAt      time.Time
+	listeners      int // listener count when the track started
+	start          time.Duration
+	bytesPerSecond int
+}
+
+// elapsed returns the position within the track.
+func (c *playCursor) elapsed() time.Duration {
+	return c.start + c.played()
+}
+
+// played returns how much of the track has gone on air in this run.
+func (c *playCursor) played() time.Duration {
+	return c.duration(int(c.mixer.onAir.Load()))
+}
+
+// duration converts a length of PCM to playing time.
+func (c *playCursor) duration(n int) time.Duration {
+	return time.Duration(float64(n) / float64(c.bytesPerSecond) * float64(time.Second))
+}
+
+// SetCheckpoint makes the broadcaster save the playback position through
+// store every interval. A zero interval disables checkpoints.
+func (b *Broadcaster) SetCheckpoint(store *playlist.Store, interval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.checkpointStore = store
+	b.checkpointEvery = interval
+}
+
+// startCursor begins tracking a track taken from pl, which has just been
+// started on mixer, and returns where decoding should start: the
+// checkpointed offset if this is the track that was on air before a
+// restart, zero otherwise. Jingles and legacy tracks are not tracked.
+func (b *Broadcaster) startCursor(track *playlist.Track, pl *playlist.Playlist, mixer *crossfader) time.Duration {
+	master := b.master()
+	if master == nil || pl == nil || track.Jingle {
+		b.cursor.Store((*playCursor)(nil))
+		return 0
+	}
+	start := master.TakeResumeOffset(track)
+	if start > 0 {
+		slog.Info("Resuming track", "track", track.Title, "offset", start.Round(time.Second))
+	}
+	c := &playCursor{
+		mixer:          mixer,
+		track:          track,
+		tag:            master.ActiveTag(),
+		playlistID:     pl.ID,
+		playlistName:   pl.Name,
+		checksum:       track.Checksum,
+		startedAt:      time.Now().UTC(),
+		listeners:      b.ActiveClients(),
+		start:          start,
+		bytesPerSecond: b.encoder.BytesPerSecond(),
+	}
+	b.cursor.Store(c)
+	return start
+}
+
+// SaveCheckpoint saves the position of the track on air now, e.g. on
+// shutdown. It does nothing if checkpoints are disabled.
+func (b *Broadcaster) SaveCheckpoint() {
+	b.mu.RLock()
+	store, every := b.checkpointStore, b.checkpointEvery
+	b.mu.RUnlock()
+	if store != nil && every > 0 {
+		b.checkpoint(store)
+	}
+}
+
+// checkpoint saves the position of the track on air, if any.
+func (b *Broadcaster) checkpoint(store *playlist.Store) {
+	c, _ := b.cursor.Load().(*playCursor)
+	if c == nil {
+		return
+	}
+	pos := playlist.PlaybackPosition{
+		Tag:           c.tag,
+		PlaylistID:    c.playlistID,
+		TrackChecksum: c.checksum,
+		Offset:        c.elapsed().Seconds(),
+		SavedAt:       time.Now().UTC(),
+	}
+	if err := store.SavePosition(pos); err != nil {
+		slog.Error("Failed to save playback position", "error", err)
+	}
+}
+
+// runCheckpoints saves the playback position periodically until ctx is
+// cancelled.
+func (b *Broadcaster) runCheckpoints(ctx context.Context) {
+	b.mu.RLock()
+	store, every := b.checkpointStore, b.checkpointEvery
+	b.mu.RUnlock()
+	if store == nil || every <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkpoint(store)
+		}
+	}
+}
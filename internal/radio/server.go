@@ -7,8 +7,12 @@ import (
 	"time"
 
 	"github.com/arung-agamani/denpa-radio/config"
+	"github.com/arung-agamani/denpa-radio/internal/archive"
 	"github.com/arung-agamani/denpa-radio/internal/auth"
+	"github.com/arung-agamani/denpa-radio/internal/events"
 	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/history"
+	"github.com/arung-agamani/denpa-radio/internal/listener"
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
 	"github.com/arung-agamani/denpa-radio/internal/radio/handler"
 	"github.com/arung-agamani/denpa-radio/internal/radio/service"
@@ -24,7 +28,14 @@ type Server struct {
 	store       *playlist.Store
 	scheduler   *playlist.Scheduler
 	broadcaster *Broadcaster
+	mounts      []MountConfig
+	hls         *HLSSegmenter
+	relays      []*Relay
+	recorder    *Recorder
+	sessions    *listener.Tracker
+	plays       *history.Log
 	auth        *auth.Auth
+	events      *events.Bus
 	httpServer  *http.Server
 
 	// Services
@@ -32,17 +43,27 @@ type Server struct {
 	playlistSvc *service.PlaylistService
 	masterSvc   *service.MasterService
 	radioSvc    *service.RadioService
+	listenerSvc *service.ListenerService
+	historySvc  *service.HistoryService
+	recordSvc   *service.RecordingService // nil when recording is disabled
 
 	// Route handlers
 	trackH    *handler.TrackHandlers
 	playlistH *handler.PlaylistHandlers
 	masterH   *handler.MasterHandlers
 	radioH    *handler.RadioHandlers
+	listenerH *handler.ListenerHandlers
+	historyH  *handler.HistoryHandlers
+	recordH   *handler.RecordingHandlers
+	eventsH   *handler.EventHandlers
 	authH     *handler.AuthHandlers
 	spaH      *handler.SPAHandler
 }
 
 func NewServer(cfg *config.Config) *Server {
+	// The encoder also probes tracks during the startup scan.
+	encoder := ffmpeg.NewEncoder(cfg.Bitrate, cfg.SampleRate, cfg.Channels)
+
 	// --- Playlist store / master initialisation ---
 	store, err := playlist.NewStore(cfg.PlaylistFile)
 	if err != nil {
@@ -78,7 +99,7 @@ func NewServer(cfg *config.Config) *Server {
 			}
 		}
 
-		defaultPl, err := playlist.BuildDefaultPlaylistWithLibrary(cfg.MusicDir, master.Library)
+		defaultPl, err := playlist.BuildDefaultPlaylistWithLibrary(cfg.MusicDir, master.Library, encoder)
 		if err != nil {
 			slog.Warn("Failed to build default playlist from music directory", "error", err)
 			defaultPl = playlist.NewPlaylist("Default Playlist", playlist.CurrentTimeTag())
@@ -89,21 +110,26 @@ func NewServer(cfg *config.Config) *Server {
 		if err := master.AssignPlaylist(tag, defaultPl); err != nil {
 			slog.Error("Failed to assign default playlist", "error", err)
 		}
-		master.SetActiveTag(playlist.CurrentTimeTag())
+		master.ResolveActiveTag()
 
 		if saveErr := store.Save(master); saveErr != nil {
 			slog.Error("Failed to save initial playlist", "error", saveErr)
 		}
 	} else {
 		if master.Library != nil {
-			_, added, scanErr := playlist.ScanIntoLibrary(cfg.MusicDir, master.Library)
+			_, added, scanErr := playlist.ScanIntoLibrary(cfg.MusicDir, master.Library, encoder)
 			if scanErr != nil {
 				slog.Warn("Failed to scan music directory into library", "error", scanErr)
-			} else if added > 0 {
-				slog.Info("Discovered new tracks during startup scan",
-					"newly_added", added,
-					"library_total", master.Library.Count(),
-				)
+			} else {
+				if added > 0 {
+					slog.Info("Discovered new tracks during startup scan",
+						"newly_added", added,
+						"library_total", master.Library.Count(),
+					)
+				}
+				master.RefreshSmartPlaylists()
+				// Save even without new tracks: the scan refreshes the
+				// probed stream properties of known ones.
 				if saveErr := store.Save(master); saveErr != nil {
 					slog.Error("Failed to save after startup scan", "error", saveErr)
 				}
@@ -113,10 +139,108 @@ func NewServer(cfg *config.Config) *Server {
 
 	master.ResolveActiveTag()
 
-	// --- Broadcaster & encoder ---
-	encoder := ffmpeg.NewEncoder(cfg.Bitrate, cfg.SampleRate, cfg.Channels)
+	// --- Resume where the previous run left off ---
+	if cfg.PlaybackCheckpointSeconds > 0 {
+		pos, err := store.LoadPosition()
+		switch {
+		case err != nil:
+			slog.Warn("Failed to load playback position, starting fresh", "error", err)
+		case master.RestorePosition(pos):
+			slog.Info("Restored playback position",
+				"playlist_id", pos.PlaylistID,
+				"offset_seconds", pos.Offset,
+				"saved_at", pos.SavedAt,
+			)
+		}
+	}
+
+	// --- Real-time events ---
+	bus := events.NewBus()
+
+	// --- Broadcaster ---
 	broadcaster := NewBroadcaster(nil, encoder)
 	broadcaster.SetMasterPlaylist(master)
+	broadcaster.SetEvents(bus)
+	broadcaster.SetCrossfade(cfg.Crossfade)
+	broadcaster.SetLoudnessTarget(cfg.LoudnessTarget)
+	broadcaster.SetFallback(ParseFallbackSource(cfg.FallbackSource))
+	broadcaster.SetPauseSource(ParseFallbackSource(cfg.PauseSource))
+	broadcaster.SetCheckpoint(store, time.Duration(cfg.PlaybackCheckpointSeconds)*time.Second)
+	broadcaster.SetBurst(time.Duration(cfg.BurstSeconds * float64(time.Second)))
+	broadcaster.SetSlowClientPolicy(SlowClientPolicy{
+		MaxDroppedFrames: cfg.SlowClientMaxDroppedFrames,
+		MaxBacklog:       time.Duration(cfg.SlowClientMaxBacklogSeconds * float64(time.Second)),
+	})
+
+	if cfg.JingleDir != "" {
+		jingles, err := LoadJingles(cfg.JingleDir)
+		if err != nil {
+			slog.Warn("Failed to load jingles, jingles disabled", "error", err)
+		} else {
+			broadcaster.SetJingles(jingles, JingleRules{
+				EveryTracks: cfg.JingleEveryTracks,
+				Every:       time.Duration(cfg.JingleEveryMinutes) * time.Minute,
+				TopOfHour:   cfg.JingleTopOfHour,
+			})
+			slog.Info("Jingles loaded", "count", len(jingles), "dir", cfg.JingleDir)
+		}
+	}
+
+	mounts, err := ParseMounts(cfg.Mounts, cfg.Bitrate)
+	if err != nil {
+		slog.Warn("Invalid MOUNTS from config, serving only the default mount",
+			"mounts", cfg.Mounts, "error", err)
+		mounts, _ = ParseMounts("", cfg.Bitrate)
+	}
+	broadcaster.SetMounts(mounts)
+
+	var hls *HLSSegmenter
+	if cfg.HLSMount != "" {
+		hls, err = NewHLSSegmenter(broadcaster, HLSConfig{
+			Mount:           cfg.HLSMount,
+			SegmentDuration: time.Duration(cfg.HLSSegmentSeconds) * time.Second,
+			Window:          cfg.HLSWindow,
+		})
+		if err != nil {
+			slog.Warn("Invalid HLS configuration, HLS output disabled", "error", err)
+			hls = nil
+		}
+	}
+
+	// --- Upstream relays ---
+	relayCfgs, err := ParseRelays(cfg.Relays)
+	if err != nil {
+		slog.Warn("Invalid RELAYS from config, relaying disabled", "error", err)
+		relayCfgs = nil
+	}
+	backoffMin := time.Duration(max(cfg.RelayBackoffMinSeconds, 1)) * time.Second
+	backoffMax := max(time.Duration(cfg.RelayBackoffMaxSeconds)*time.Second, backoffMin)
+	var relays []*Relay
+	for _, rc := range relayCfgs {
+		relays = append(relays, NewRelay(broadcaster, rc, cfg.StationName, backoffMin, backoffMax))
+	}
+
+	// --- Stream archive ---
+	var recorder *Recorder
+	var recordSvc *service.RecordingService
+	if cfg.ArchiveDir != "" {
+		recorder, recordSvc = newRecorder(broadcaster, cfg)
+	}
+
+	// --- Listener sessions ---
+	sessions, err := listener.NewTracker(cfg.ListenerHistoryFile, time.Duration(cfg.ListenerHistoryDays)*24*time.Hour)
+	if err != nil {
+		slog.Error("Failed to create listener tracker", "error", err)
+		panic(err)
+	}
+
+	// --- Play history ---
+	plays, err := history.New(cfg.PlayHistoryFile, time.Duration(cfg.PlayHistoryDays)*24*time.Hour)
+	if err != nil {
+		slog.Error("Failed to open play history", "error", err)
+		panic(err)
+	}
+	broadcaster.SetHistory(plays)
 
 	// --- Auth ---
 	authInstance := auth.New(auth.Config{
@@ -133,26 +257,52 @@ func NewServer(cfg *config.Config) *Server {
 		slog.Info("Scheduler triggered playlist switch",
 			"previous_tag", event.PreviousTag,
 			"new_tag", event.NewTag,
+			"day", event.Day,
 		)
+		var override string
+		if event.Override != nil {
+			override = event.Override.Name
+		}
 		if event.Playlist != nil {
 			slog.Info("Switching to playlist",
 				"playlist_name", event.Playlist.Name,
 				"playlist_id", event.Playlist.ID,
 			)
 		}
+		if event.HardStart {
+			// Start the new slot on time instead of after the current track.
+			broadcaster.Skip()
+		}
+		bus.Publish(events.TypeTagSwitched, events.TagSwitched{
+			Previous:  event.PreviousTag,
+			Current:   event.NewTag,
+			Day:       event.Day,
+			Override:  override,
+			HardStart: event.HardStart,
+			Playlist:  event.Playlist,
+		})
 	}, 1*time.Minute)
 
 	// --- Services ---
 	trackSvc := service.NewTrackService(master, store, cfg, encoder)
-	playlistSvc := service.NewPlaylistService(master, store, cfg)
-	masterSvc := service.NewMasterService(master, store, scheduler)
-	radioSvc := service.NewRadioService(master, store, scheduler, broadcaster, cfg)
+	playlistSvc := service.NewPlaylistService(master, store, cfg, bus)
+	masterSvc := service.NewMasterService(master, store, scheduler, bus)
+	radioSvc := service.NewRadioService(master, store, scheduler, broadcaster, cfg, bus, encoder)
+	listenerSvc := service.NewListenerService(sessions, master)
+	historySvc := service.NewHistoryService(plays)
 
 	// --- Route handlers ---
 	trackH := handler.NewTrackHandlers(trackSvc)
 	playlistH := handler.NewPlaylistHandlers(playlistSvc)
 	masterH := handler.NewMasterHandlers(masterSvc)
 	radioH := handler.NewRadioHandlers(radioSvc)
+	listenerH := handler.NewListenerHandlers(listenerSvc)
+	historyH := handler.NewHistoryHandlers(historySvc)
+	var recordH *handler.RecordingHandlers
+	if recordSvc != nil {
+		recordH = handler.NewRecordingHandlers(recordSvc)
+	}
+	eventsH := handler.NewEventHandlers(bus, radioSvc)
 	authH := handler.NewAuthHandlers(authInstance)
 	spaH := handler.NewSPAHandler(cfg.WebDir)
 
@@ -162,15 +312,29 @@ func NewServer(cfg *config.Config) *Server {
 		store:       store,
 		scheduler:   scheduler,
 		broadcaster: broadcaster,
+		mounts:      mounts,
+		hls:         hls,
+		relays:      relays,
+		recorder:    recorder,
+		sessions:    sessions,
+		plays:       plays,
 		auth:        authInstance,
+		events:      bus,
 		trackSvc:    trackSvc,
 		playlistSvc: playlistSvc,
 		masterSvc:   masterSvc,
 		radioSvc:    radioSvc,
+		listenerSvc: listenerSvc,
+		historySvc:  historySvc,
+		recordSvc:   recordSvc,
 		trackH:      trackH,
 		playlistH:   playlistH,
 		masterH:     masterH,
 		radioH:      radioH,
+		listenerH:   listenerH,
+		historyH:    historyH,
+		recordH:     recordH,
+		eventsH:     eventsH,
 		authH:       authH,
 		spaH:        spaH,
 	}
@@ -197,10 +361,22 @@ func NewServer(cfg *config.Config) *Server {
 
 // registerRoutes wires all routes onto the gin engine.
 func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
-	streamHandler := NewStreamHandler(s.broadcaster, s.config.StationName, s.config.MaxClients)
-
 	// --- Streaming (no auth) ---
-	engine.GET("/stream", gin.WrapH(streamHandler))
+	for _, m := range s.mounts {
+		streamHandler := NewStreamHandler(s.broadcaster, s.sessions, m, s.config.StationName, s.config.MaxClients)
+		engine.GET(m.Path, gin.WrapH(streamHandler))
+	}
+	if s.hls != nil {
+		engine.GET(HLSPath+"/:file", gin.WrapH(s.hls))
+	}
+
+	// --- Live DJ input (source-client basic auth) ---
+	if s.config.SourcePassword != "" {
+		source := NewSourceHandler(s.broadcaster, s.config.SourceMount, s.config.SourceUsername, s.config.SourcePassword)
+		engine.Handle("SOURCE", s.config.SourceMount, gin.WrapH(source))
+		engine.PUT(s.config.SourceMount, gin.WrapH(source))
+		engine.GET("/admin/metadata", gin.WrapF(source.Metadata))
+	}
 
 	// --- Public non-API ---
 	engine.GET("/health", s.radioH.Health)
@@ -221,7 +397,12 @@ func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
 		api.GET("/scheduler/status", s.radioH.SchedulerStatus)
 		api.GET("/timezone", s.radioH.GetTimezone)
 		api.GET("/master", s.masterH.Get)
+		api.GET("/master/slots", s.masterH.ListSlots)
+		api.GET("/master/week", s.masterH.Week)
+		api.GET("/master/overrides", s.masterH.ListOverrides)
 		api.GET("/queue", s.radioH.GetQueue)
+		api.GET("/events", s.eventsH.Stream)
+		api.GET("/history", s.historyH.List)
 
 		// Literal sub-paths registered before :id to avoid routing conflicts.
 		api.GET("/tracks/search", s.trackH.Search)
@@ -242,6 +423,8 @@ func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
 		protected.DELETE("/tracks/:id", s.trackH.Delete)
 		protected.POST("/tracks/scan", s.trackH.Scan)
 		protected.POST("/tracks/upload", s.trackH.Upload)
+		protected.GET("/tracks/loudness", s.trackH.LoudnessStatus)
+		protected.POST("/tracks/loudness", s.trackH.AnalyzeLoudness)
 
 		// Playlist CRUD
 		protected.POST("/playlists", s.playlistH.Create)
@@ -261,6 +444,12 @@ func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
 		// Master playlist tag management
 		protected.PUT("/master/:tag", s.masterH.AssignPlaylistToTag)
 		protected.DELETE("/master/:tag/:playlistId", s.masterH.RemovePlaylistFromTag)
+		protected.POST("/master/slots", s.masterH.CreateSlot)
+		protected.PUT("/master/slots/:name", s.masterH.UpdateSlot)
+		protected.DELETE("/master/slots/:name", s.masterH.DeleteSlot)
+		protected.POST("/master/overrides", s.masterH.CreateOverride)
+		protected.PUT("/master/overrides/:id", s.masterH.UpdateOverride)
+		protected.DELETE("/master/overrides/:id", s.masterH.DeleteOverride)
 
 		// Reconcile & timezone
 		protected.POST("/reconcile", s.radioH.Reconcile)
@@ -269,6 +458,25 @@ func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
 		// Skip controls
 		protected.POST("/skip/next", s.radioH.SkipNext)
 		protected.POST("/skip/prev", s.radioH.SkipPrev)
+		protected.POST("/pause", s.radioH.Pause)
+		protected.POST("/resume", s.radioH.Resume)
+
+		// Listener sessions & statistics
+		protected.GET("/listeners", s.listenerH.List)
+		protected.GET("/listeners/history", s.listenerH.History)
+		protected.GET("/listeners/stats", s.listenerH.Stats)
+		protected.DELETE("/listeners/:id", s.listenerH.Kick)
+
+		// Play history including file paths
+		protected.GET("/history/full", s.historyH.Full)
+
+		// Stream archive
+		if s.recordH != nil {
+			protected.GET("/recordings", s.recordH.List)
+			protected.GET("/recordings/:name", s.recordH.Get)
+			protected.GET("/recordings/:name/download", s.recordH.Download)
+			protected.DELETE("/recordings/:name", s.recordH.Delete)
+		}
 
 		// Legacy protected reload
 		protected.POST("/playlist/reload", s.radioH.LegacyReload)
@@ -278,11 +486,47 @@ func (s *Server) registerRoutes(engine *gin.Engine, authInstance *auth.Auth) {
 	engine.NoRoute(s.spaH.Handle)
 }
 
+// newRecorder sets up the stream archive from cfg. Misconfiguration is
+// logged and disables recording rather than stopping the station.
+func newRecorder(b *Broadcaster, cfg *config.Config) (*Recorder, *service.RecordingService) {
+	rotation, err := ParseRecordRotation(cfg.ArchiveRotation)
+	if err != nil {
+		slog.Warn("Invalid ARCHIVE_ROTATION from config, using hourly", "error", err)
+		rotation = RotateHourly
+	}
+	a, err := archive.New(cfg.ArchiveDir,
+		time.Duration(cfg.ArchiveMaxAgeDays)*24*time.Hour,
+		int64(cfg.ArchiveMaxSizeMB)*1024*1024,
+	)
+	if err != nil {
+		slog.Warn("Failed to open archive, recording disabled", "error", err)
+		return nil, nil
+	}
+	rec, err := NewRecorder(b, a, cfg.ArchiveMount, rotation)
+	if err != nil {
+		slog.Warn("Invalid archive configuration, recording disabled", "error", err)
+		return nil, nil
+	}
+	return rec, service.NewRecordingService(a)
+}
+
 // Start launches the scheduler, broadcaster, and HTTP server. It blocks until
 // ctx is cancelled and then performs a graceful shutdown.
 func (s *Server) Start(ctx context.Context) error {
 	go s.scheduler.Start(ctx)
 	go s.broadcaster.Start(ctx)
+	go s.plays.Run(ctx)
+	go s.sessions.Run(ctx)
+	go s.trackSvc.RunLoudnessAnalysis(ctx)
+	if s.hls != nil {
+		go s.hls.Start(ctx)
+	}
+	for _, r := range s.relays {
+		go r.Start(ctx)
+	}
+	if s.recorder != nil {
+		go s.recorder.Start(ctx)
+	}
 
 	errChan := make(chan error, 1)
 	go func() {
@@ -296,8 +540,13 @@ func (s *Server) Start(ctx context.Context) error {
 	case err := <-errChan:
 		return err
 	case <-ctx.Done():
+		s.broadcaster.SaveCheckpoint()
+		s.plays.Flush()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		return s.httpServer.Shutdown(shutdownCtx)
+		err := s.httpServer.Shutdown(shutdownCtx)
+		// Listeners disconnected by the shutdown are in the history now.
+		s.sessions.Flush()
+		return err
 	}
 }
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/history"
+)
+
+// maxHistoryPage bounds how many plays one history request may return.
+const maxHistoryPage = 500
+
+// HistoryService implements the business logic for querying the play log.
+type HistoryService struct {
+	log *history.Log
+}
+
+func NewHistoryService(log *history.Log) *HistoryService {
+	return &HistoryService{log: log}
+}
+
+// List returns a page of plays started in [from, to), most recent first,
+// and the total number of plays in that range. Zero times leave that end
+// of the range open.
+func (s *HistoryService) List(from, to time.Time, offset, limit int) ([]history.Play, int, error) {
+	if limit < 1 || limit > maxHistoryPage {
+		return nil, 0, fmt.Errorf("invalid limit: must be between 1 and %d", maxHistoryPage)
+	}
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset: must not be negative")
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, 0, fmt.Errorf("invalid range: from must be before to")
+	}
+	plays, total := s.log.List(history.Query{From: from, To: to, Offset: offset, Limit: limit})
+	return plays, total, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/arung-agamani/denpa-radio/internal/listener"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// maxStatsDays bounds how far back the daily listening report may reach.
+const maxStatsDays = 366
+
+// ListenerService implements the business logic for listener session
+// inspection, kicking and listening statistics.
+type ListenerService struct {
+	tracker *listener.Tracker
+	master  *playlist.MasterPlaylist
+}
+
+func NewListenerService(tracker *listener.Tracker, master *playlist.MasterPlaylist) *ListenerService {
+	return &ListenerService{tracker: tracker, master: master}
+}
+
+// Active returns the sessions of all currently connected listeners.
+func (s *ListenerService) Active() []listener.Session {
+	return s.tracker.Active()
+}
+
+// History returns up to limit finished sessions, most recent first.
+func (s *ListenerService) History(limit int) []listener.Session {
+	return s.tracker.History(limit)
+}
+
+// Kick disconnects the listener with the given session id.
+func (s *ListenerService) Kick(id string) error {
+	return s.tracker.Kick(id)
+}
+
+// DailyStats returns listening hours per day for the last days days in the
+// station timezone.
+func (s *ListenerService) DailyStats(days int) ([]listener.DayStats, error) {
+	if days < 1 || days > maxStatsDays {
+		return nil, fmt.Errorf("invalid days: must be between 1 and %d", maxStatsDays)
+	}
+	return s.tracker.DailyStats(days, s.master.Location()), nil
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// loudnessSaveEvery is how many analysed tracks are batched before the
+// library is persisted mid-job.
+const loudnessSaveEvery = 20
+
+// LoudnessStatus reports the progress of the background loudness analysis.
+type LoudnessStatus struct {
+	Running    bool       `json:"running"`
+	Queued     int        `json:"queued"`
+	Done       int        `json:"done"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// loudnessJob analyses queued tracks one at a time in the background so
+// scans and uploads return immediately.
+type loudnessJob struct {
+	master  *playlist.MasterPlaylist
+	encoder ffmpeg.Encoder
+	save    func()
+
+	mu     sync.Mutex
+	queue  []int64
+	queued map[int64]bool
+	status LoudnessStatus
+	wake   chan struct{}
+}
+
+func newLoudnessJob(master *playlist.MasterPlaylist, encoder ffmpeg.Encoder, save func()) *loudnessJob {
+	return &loudnessJob{
+		master:  master,
+		encoder: encoder,
+		save:    save,
+		queued:  make(map[int64]bool),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// enqueue schedules tracks for analysis. Tracks that already have a
+// measurement are skipped unless force is set. Returns how many were queued.
+func (j *loudnessJob) enqueue(tracks []*playlist.Track, force bool) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n := 0
+	for _, t := range tracks {
+		if t == nil || j.queued[t.ID] || (!force && t.Loudness != nil) {
+			continue
+		}
+		j.queue = append(j.queue, t.ID)
+		j.queued[t.ID] = true
+		n++
+	}
+	if n > 0 {
+		if !j.status.Running {
+			now := time.Now().UTC()
+			j.status = LoudnessStatus{Running: true, StartedAt: &now}
+		}
+		j.status.Queued = len(j.queue)
+		select {
+		case j.wake <- struct{}{}:
+		default:
+		}
+	}
+	return n
+}
+
+// snapshot returns the current job status.
+func (j *loudnessJob) snapshot() LoudnessStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// next pops the next queued track ID. ok is false when the queue is empty,
+// in which case the job is marked finished.
+func (j *loudnessJob) next() (id int64, ok bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.queue) == 0 {
+		if j.status.Running {
+			now := time.Now().UTC()
+			j.status.Running = false
+			j.status.FinishedAt = &now
+		}
+		return 0, false
+	}
+	id = j.queue[0]
+	j.queue = j.queue[1:]
+	delete(j.queued, id)
+	j.status.Queued = len(j.queue)
+	return id, true
+}
+
+func (j *loudnessJob) record(failed bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if failed {
+		j.status.Failed++
+	} else {
+		j.status.Done++
+	}
+}
+
+// run processes the queue until ctx is cancelled.
+func (j *loudnessJob) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.wake:
+		}
+
+		unsaved := 0
+		for ctx.Err() == nil {
+			id, ok := j.next()
+			if !ok {
+				break
+			}
+			if j.analyse(ctx, id) {
+				unsaved++
+			}
+			if unsaved >= loudnessSaveEvery {
+				j.save()
+				unsaved = 0
+			}
+		}
+		if unsaved > 0 {
+			j.save()
+		}
+	}
+}
+
+// analyse measures one track and stores the result. Returns true if the
+// library was updated.
+func (j *loudnessJob) analyse(ctx context.Context, id int64) bool {
+	t := j.master.Library.GetByID(id)
+	if t == nil {
+		return false
+	}
+	l, err := j.encoder.AnalyzeLoudness(ctx, t.FilePath)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("Loudness analysis failed", "track_id", id, "title", t.Title, "error", err)
+			j.record(true)
+		}
+		return false
+	}
+	if _, err := j.master.Library.SetLoudness(id, l.Integrated, l.TruePeak); err != nil {
+		j.record(true)
+		return false
+	}
+	j.record(false)
+	slog.Debug("Track loudness analysed",
+		"track_id", id,
+		"integrated_lufs", l.Integrated,
+		"true_peak_dbtp", l.TruePeak,
+	)
+	return true
+}
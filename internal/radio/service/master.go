@@ -3,7 +3,10 @@ package service
 import (
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/arung-agamani/denpa-radio/internal/events"
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
 )
 
@@ -18,6 +21,8 @@ type MasterSnapshot struct {
 	ActiveTag        playlist.TimeTag
 	ActivePlaylistID *int64
 	TotalTracks      int
+	ActiveDay        time.Weekday
+	Slots            []playlist.TimeSlot
 	Tags             map[string]MasterTagInfo
 }
 
@@ -27,10 +32,11 @@ type MasterService struct {
 	master    *playlist.MasterPlaylist
 	store     *playlist.Store
 	scheduler *playlist.Scheduler
+	events    *events.Bus
 }
 
-func NewMasterService(master *playlist.MasterPlaylist, store *playlist.Store, scheduler *playlist.Scheduler) *MasterService {
-	return &MasterService{master: master, store: store, scheduler: scheduler}
+func NewMasterService(master *playlist.MasterPlaylist, store *playlist.Store, scheduler *playlist.Scheduler, bus *events.Bus) *MasterService {
+	return &MasterService{master: master, store: store, scheduler: scheduler, events: bus}
 }
 
 func (s *MasterService) save() {
@@ -42,7 +48,7 @@ func (s *MasterService) save() {
 // Get returns a snapshot of the full master playlist structure.
 func (s *MasterService) Get() MasterSnapshot {
 	tags := make(map[string]MasterTagInfo)
-	for _, tag := range playlist.ValidTimeTags {
+	for _, tag := range s.master.SlotNames() {
 		pls := s.master.GetPlaylists(tag)
 		tags[string(tag)] = MasterTagInfo{Playlists: pls, Count: len(pls)}
 	}
@@ -56,16 +62,28 @@ func (s *MasterService) Get() MasterSnapshot {
 		ActiveTag:        activeTag,
 		ActivePlaylistID: activePlaylistID,
 		TotalTracks:      s.master.TotalTracks(),
+		ActiveDay:        s.master.ActiveDay(),
+		Slots:            s.master.Slots(),
 		Tags:             tags,
 	}
 }
 
-// AssignPlaylistToTag moves or assigns a playlist to a specific time tag.
-func (s *MasterService) AssignPlaylistToTag(playlistID int64, tagStr string) error {
-	if !playlist.IsValidTimeTag(tagStr) {
-		return fmt.Errorf("invalid tag: must be one of morning, afternoon, evening, night")
-	}
+// AssignPlaylistToTag moves or assigns a playlist to a specific time tag. If
+// days is non-nil the playlist only plays on those days of the week within
+// the tag; day names and the "weekdays", "weekend" and "daily" shortcuts are
+// accepted, and an empty list means every day.
+func (s *MasterService) AssignPlaylistToTag(playlistID int64, tagStr string, days []string) error {
 	tag := playlist.TimeTag(tagStr)
+	if !s.master.HasSlot(tag) {
+		return invalidTagError(s.master)
+	}
+	var weekdays playlist.Weekdays
+	if days != nil {
+		var err error
+		if weekdays, err = playlist.ParseWeekdays(days); err != nil {
+			return err
+		}
+	}
 	pl, currentTag, err := s.master.FindPlaylistByID(playlistID)
 	if err != nil {
 		return err
@@ -79,20 +97,200 @@ func (s *MasterService) AssignPlaylistToTag(playlistID int64, tagStr string) err
 	if err := s.master.AssignPlaylist(tag, pl); err != nil {
 		return err
 	}
+	if days != nil {
+		if err := s.master.SetPlaylistDays(playlistID, weekdays); err != nil {
+			return err
+		}
+	}
 	s.save()
+	s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: "tag_assigned", PlaylistID: playlistID})
 	s.scheduler.ForceCheck()
 	return nil
 }
 
 // RemovePlaylistFromTag removes a playlist from a specific time tag.
 func (s *MasterService) RemovePlaylistFromTag(tagStr string, playlistID int64) error {
-	if !playlist.IsValidTimeTag(tagStr) {
-		return fmt.Errorf("invalid tag: must be one of morning, afternoon, evening, night")
-	}
 	tag := playlist.TimeTag(tagStr)
 	if err := s.master.RemovePlaylist(tag, playlistID); err != nil {
 		return err
 	}
 	s.save()
+	s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: "tag_removed", PlaylistID: playlistID})
 	return nil
 }
+
+// DaySchedule is one day of the weekly grid: the playlists each time slot
+// plays on that day.
+type DaySchedule struct {
+	Day   string                       `json:"day"`
+	Slots map[string][]PlaylistSummary `json:"slots"`
+}
+
+// Week returns the weekly grid, Monday first.
+func (s *MasterService) Week() []DaySchedule {
+	week := s.master.Week()
+	grid := make([]DaySchedule, 0, len(week))
+	for _, d := range week {
+		day := DaySchedule{
+			Day:   strings.ToLower(d.Day.String()),
+			Slots: make(map[string][]PlaylistSummary, len(d.Slots)),
+		}
+		for tag, pls := range d.Slots {
+			summaries := make([]PlaylistSummary, 0, len(pls))
+			for _, pl := range pls {
+				summaries = append(summaries, PlaylistSummary{ID: pl.ID, Name: pl.Name, Tag: pl.Tag, TrackCount: pl.Count()})
+			}
+			day.Slots[string(tag)] = summaries
+		}
+		grid = append(grid, day)
+	}
+	return grid
+}
+
+// invalidTagError lists the station's slots for a tag that is not one of them.
+func invalidTagError(master *playlist.MasterPlaylist) error {
+	names := master.SlotNames()
+	strs := make([]string, len(names))
+	for i, n := range names {
+		strs[i] = string(n)
+	}
+	return fmt.Errorf("invalid tag: must be one of %s", strings.Join(strs, ", "))
+}
+
+// SlotUpdate holds the fields of a time slot to change; nil fields are kept.
+type SlotUpdate struct {
+	Name      *string
+	Start     *string
+	End       *string
+	HardStart *bool
+}
+
+// ListSlots returns the slot table.
+func (s *MasterService) ListSlots() []playlist.TimeSlot {
+	return s.master.Slots()
+}
+
+// CreateSlot adds a time slot running from start to end ("HH:MM"). A
+// hard-start slot cuts the track on air when it begins.
+func (s *MasterService) CreateSlot(name, start, end string, hardStart bool) (playlist.TimeSlot, error) {
+	slot, err := buildSlot(name, start, end)
+	if err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	slot.HardStart = hardStart
+	if err := s.master.AddSlot(slot); err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	s.slotsChanged()
+	return slot, nil
+}
+
+// UpdateSlot renames or retimes an existing slot. Renaming moves the
+// playlists assigned to it.
+func (s *MasterService) UpdateSlot(name string, upd SlotUpdate) (playlist.TimeSlot, error) {
+	var current *playlist.TimeSlot
+	for _, sl := range s.master.Slots() {
+		if sl.Name == playlist.TimeTag(name) {
+			current = &sl
+			break
+		}
+	}
+	if current == nil {
+		return playlist.TimeSlot{}, fmt.Errorf("time slot %q not found", name)
+	}
+
+	newName, start, end := string(current.Name), current.Start.String(), current.End.String()
+	if upd.Name != nil {
+		newName = *upd.Name
+	}
+	if upd.Start != nil {
+		start = *upd.Start
+	}
+	if upd.End != nil {
+		end = *upd.End
+	}
+	slot, err := buildSlot(newName, start, end)
+	if err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	slot.HardStart = current.HardStart
+	if upd.HardStart != nil {
+		slot.HardStart = *upd.HardStart
+	}
+	if err := s.master.UpdateSlot(current.Name, slot); err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	s.slotsChanged()
+	return slot, nil
+}
+
+// DeleteSlot removes a slot that no longer has playlists assigned.
+func (s *MasterService) DeleteSlot(name string) error {
+	if err := s.master.RemoveSlot(playlist.TimeTag(name)); err != nil {
+		return err
+	}
+	s.slotsChanged()
+	return nil
+}
+
+// slotsChanged persists the slot table and lets the scheduler pick up a
+// different active slot straight away.
+func (s *MasterService) slotsChanged() {
+	s.save()
+	s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: "slots_changed"})
+	s.scheduler.ForceCheck()
+}
+
+// ListOverrides returns the schedule overrides.
+func (s *MasterService) ListOverrides() []playlist.ScheduleOverride {
+	return s.master.Overrides()
+}
+
+// CreateOverride adds a schedule override.
+func (s *MasterService) CreateOverride(o playlist.ScheduleOverride) (playlist.ScheduleOverride, error) {
+	created, err := s.master.AddOverride(o)
+	if err != nil {
+		return playlist.ScheduleOverride{}, err
+	}
+	s.overridesChanged()
+	return created, nil
+}
+
+// UpdateOverride replaces a schedule override.
+func (s *MasterService) UpdateOverride(id int64, o playlist.ScheduleOverride) (playlist.ScheduleOverride, error) {
+	updated, err := s.master.UpdateOverride(id, o)
+	if err != nil {
+		return playlist.ScheduleOverride{}, err
+	}
+	s.overridesChanged()
+	return updated, nil
+}
+
+// DeleteOverride removes a schedule override.
+func (s *MasterService) DeleteOverride(id int64) error {
+	if err := s.master.RemoveOverride(id); err != nil {
+		return err
+	}
+	s.overridesChanged()
+	return nil
+}
+
+// overridesChanged persists the overrides and applies or reverts one that
+// starts or stops covering today.
+func (s *MasterService) overridesChanged() {
+	s.save()
+	s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: "overrides_changed"})
+	s.scheduler.ForceCheck()
+}
+
+func buildSlot(name, start, end string) (playlist.TimeSlot, error) {
+	from, err := playlist.ParseClockTime(start)
+	if err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	to, err := playlist.ParseClockTime(end)
+	if err != nil {
+		return playlist.TimeSlot{}, err
+	}
+	return playlist.TimeSlot{Name: playlist.TimeTag(name), Start: from, End: to}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/arung-agamani/denpa-radio/config"
+	"github.com/arung-agamani/denpa-radio/internal/events"
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
 )
 
@@ -16,6 +17,7 @@ type PlaylistSummary struct {
 	Name       string           `json:"name"`
 	Tag        playlist.TimeTag `json:"tag"`
 	TrackCount int              `json:"trackCount"`
+	Smart      bool             `json:"smart,omitempty"`
 }
 
 // AddTrackInput bundles the parameters for PlaylistService.AddTrack.
@@ -33,10 +35,11 @@ type PlaylistService struct {
 	master *playlist.MasterPlaylist
 	store  *playlist.Store
 	cfg    *config.Config
+	events *events.Bus
 }
 
-func NewPlaylistService(master *playlist.MasterPlaylist, store *playlist.Store, cfg *config.Config) *PlaylistService {
-	return &PlaylistService{master: master, store: store, cfg: cfg}
+func NewPlaylistService(master *playlist.MasterPlaylist, store *playlist.Store, cfg *config.Config, bus *events.Bus) *PlaylistService {
+	return &PlaylistService{master: master, store: store, cfg: cfg, events: bus}
 }
 
 func (s *PlaylistService) save() {
@@ -45,6 +48,11 @@ func (s *PlaylistService) save() {
 	}
 }
 
+// notify publishes a playlist_modified event for the given change.
+func (s *PlaylistService) notify(action string, playlistID int64) {
+	s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: action, PlaylistID: playlistID})
+}
+
 // List returns summary information for every playlist in the master.
 func (s *PlaylistService) List() []PlaylistSummary {
 	allPls := s.master.AllPlaylists()
@@ -55,6 +63,7 @@ func (s *PlaylistService) List() []PlaylistSummary {
 			Name:       pl.Name,
 			Tag:        pl.Tag,
 			TrackCount: pl.Count(),
+			Smart:      pl.IsSmart(),
 		})
 	}
 	return summaries
@@ -65,37 +74,70 @@ func (s *PlaylistService) GetByID(id int64) (*playlist.Playlist, playlist.TimeTa
 	return s.master.FindPlaylistByID(id)
 }
 
-// Create creates a new playlist and assigns it to the given time tag.
-func (s *PlaylistService) Create(name, tag string) (*playlist.Playlist, error) {
+// Create creates a new playlist and assigns it to the given time tag. A
+// non-nil rule makes it a smart playlist filled from the library.
+func (s *PlaylistService) Create(name, tag string, rule *playlist.SmartRule) (*playlist.Playlist, error) {
 	if name == "" {
 		return nil, fmt.Errorf("name is required")
 	}
-	if !playlist.IsValidTimeTag(tag) {
-		return nil, fmt.Errorf("invalid tag: must be one of morning, afternoon, evening, night")
-	}
 	t := playlist.TimeTag(tag)
+	if !s.master.HasSlot(t) {
+		return nil, invalidTagError(s.master)
+	}
 	pl := playlist.NewPlaylist(name, t)
 	pl.SetLibrary(s.master.Library)
+	if rule != nil {
+		if err := pl.SetRule(rule); err != nil {
+			return nil, err
+		}
+		pl.Refresh(s.master.Library, s.master.Now())
+	}
 	if err := s.master.AssignPlaylist(t, pl); err != nil {
 		return nil, err
 	}
 	s.save()
+	s.notify("created", pl.ID)
 	return pl, nil
 }
 
-// Update changes the name and/or tag of an existing playlist.
-func (s *PlaylistService) Update(id int64, name, tag *string) (*playlist.Playlist, error) {
+// maxCrossfadeSeconds bounds the per-playlist crossfade override.
+const maxCrossfadeSeconds = 30
+
+// Update changes the name, tag, crossfade override and/or smart rule of an
+// existing playlist. A negative crossfade clears the override so the station
+// default applies again; likewise a rule without conditions turns a smart
+// playlist back into a regular one that keeps its current tracks.
+func (s *PlaylistService) Update(id int64, name, tag *string, crossfade *float64, rule *playlist.SmartRule) (*playlist.Playlist, error) {
 	pl, currentTag, err := s.master.FindPlaylistByID(id)
 	if err != nil {
 		return nil, err
 	}
+	if crossfade != nil && *crossfade > maxCrossfadeSeconds {
+		return nil, fmt.Errorf("invalid crossfade: must be between 0 and %d seconds", maxCrossfadeSeconds)
+	}
+	if rule != nil {
+		if len(rule.Conditions) == 0 {
+			rule = nil
+		}
+		if err := pl.SetRule(rule); err != nil {
+			return nil, err
+		}
+		pl.Refresh(s.master.Library, s.master.Now())
+	}
+	if crossfade != nil {
+		if *crossfade < 0 {
+			pl.SetCrossfade(nil)
+		} else {
+			pl.SetCrossfade(crossfade)
+		}
+	}
 	if name != nil {
 		pl.Name = *name
 	}
 	if tag != nil && playlist.TimeTag(*tag) != currentTag {
 		newTag := playlist.TimeTag(*tag)
-		if !playlist.IsValidTimeTag(*tag) {
-			return nil, fmt.Errorf("invalid tag: must be one of morning, afternoon, evening, night")
+		if !s.master.HasSlot(newTag) {
+			return nil, invalidTagError(s.master)
 		}
 		if err := s.master.RemovePlaylist(currentTag, id); err != nil {
 			return nil, err
@@ -105,6 +147,7 @@ func (s *PlaylistService) Update(id int64, name, tag *string) (*playlist.Playlis
 		}
 	}
 	s.save()
+	s.notify("updated", pl.ID)
 	return pl, nil
 }
 
@@ -117,10 +160,18 @@ func (s *PlaylistService) Delete(id int64) error {
 	if err := s.master.RemovePlaylist(tag, id); err != nil {
 		return err
 	}
+	s.master.ForgetPlaylistInOverrides(id)
 	s.save()
+	s.notify("deleted", id)
 	return nil
 }
 
+// errSmartPlaylist refuses a manual track change to a smart playlist, whose
+// tracks always follow its rule.
+func errSmartPlaylist(id int64) error {
+	return fmt.Errorf("playlist %d is a smart playlist; its tracks come from its rule", id)
+}
+
 // AddTrack resolves a track via library ID, checksum, or file path, then
 // appends it to the specified playlist at the optional index.
 func (s *PlaylistService) AddTrack(input AddTrackInput) (*playlist.Track, *playlist.Playlist, error) {
@@ -128,6 +179,9 @@ func (s *PlaylistService) AddTrack(input AddTrackInput) (*playlist.Track, *playl
 	if err != nil {
 		return nil, nil, err
 	}
+	if pl.IsSmart() {
+		return nil, nil, errSmartPlaylist(pl.ID)
+	}
 
 	lib := s.master.Library
 	var track *playlist.Track
@@ -193,6 +247,7 @@ func (s *PlaylistService) AddTrack(input AddTrackInput) (*playlist.Track, *playl
 		pl.AddTrack(track)
 	}
 	s.save()
+	s.notify("track_added", pl.ID)
 	return track, pl, nil
 }
 
@@ -202,11 +257,15 @@ func (s *PlaylistService) RemoveTrack(playlistID, trackID int64) (*playlist.Trac
 	if err != nil {
 		return nil, nil, err
 	}
+	if pl.IsSmart() {
+		return nil, nil, errSmartPlaylist(pl.ID)
+	}
 	removed, err := pl.RemoveTrackByID(trackID)
 	if err != nil {
 		return nil, nil, err
 	}
 	s.save()
+	s.notify("track_removed", pl.ID)
 	return removed, pl, nil
 }
 
@@ -220,6 +279,7 @@ func (s *PlaylistService) MoveTrack(playlistID int64, from, to int) (*playlist.P
 		return nil, err
 	}
 	s.save()
+	s.notify("track_moved", pl.ID)
 	return pl, nil
 }
 
@@ -231,6 +291,7 @@ func (s *PlaylistService) Shuffle(playlistID int64) (*playlist.Playlist, error)
 	}
 	pl.Shuffle()
 	s.save()
+	s.notify("shuffled", pl.ID)
 	return pl, nil
 }
 
@@ -261,13 +322,19 @@ func (s *PlaylistService) Import(data []byte) (*playlist.Playlist, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !playlist.IsValidTimeTag(string(pl.Tag)) {
-		pl.Tag = playlist.CurrentTimeTag()
+	if !s.master.HasSlot(pl.Tag) {
+		pl.Tag = s.master.CurrentTag()
+		if pl.Tag == "" {
+			pl.Tag = s.master.SlotNames()[0]
+		}
 	}
 	if err := s.master.AssignPlaylist(pl.Tag, pl); err != nil {
 		return nil, err
 	}
+	// The import may have brought new tracks into the library.
+	s.master.RefreshSmartPlaylists()
 	s.save()
+	s.notify("imported", pl.ID)
 	return pl, nil
 }
 
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/arung-agamani/denpa-radio/config"
+	"github.com/arung-agamani/denpa-radio/internal/events"
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
 )
 
@@ -18,6 +19,78 @@ type Broadcaster interface {
 	// Skip aborts the currently-streaming track and immediately advances to
 	// the next one.
 	Skip()
+	// Mounts describes every listener endpoint and its audience.
+	Mounts() []MountInfo
+	// FallbackReason explains why the dead-air fallback is on air, or is
+	// empty during normal playout.
+	FallbackReason() string
+	// CurrentTrackInfo returns the now-playing metadata, including that sent
+	// by a live DJ, or nil when nothing is playing.
+	CurrentTrackInfo() *playlist.Track
+	// LiveStatus describes the connected DJ source, or nil during
+	// automation.
+	LiveStatus() *LiveInfo
+	// Relays reports the connection state of each upstream relay.
+	Relays() []RelayInfo
+	// CurrentJingle describes the station ID on air, or nil while a
+	// regular track plays.
+	CurrentJingle() *JingleInfo
+	// Pause freezes the track on air; Resume continues it.
+	Pause() error
+	Resume() error
+	// PauseStatus describes the pause, or nil while playing.
+	PauseStatus() *PauseInfo
+	// Progress reports how far listeners are into the track on air, or nil
+	// for jingles and the fallback.
+	Progress() *TrackProgress
+}
+
+// TrackProgress is the position of the track on air as listeners hear it.
+// Remaining is nil when the track's duration is unknown, e.g. a live DJ.
+type TrackProgress struct {
+	StartedAt time.Time `json:"startedAt"`
+	Elapsed   float64   `json:"elapsed"`             // seconds
+	Remaining *float64  `json:"remaining,omitempty"` // seconds
+}
+
+// PauseInfo describes a paused broadcast.
+type PauseInfo struct {
+	Since    time.Time `json:"since"`
+	Duration float64   `json:"durationSeconds"`
+}
+
+// JingleInfo describes a station ID that is on air.
+type JingleInfo struct {
+	Title string `json:"title"`
+	File  string `json:"file"`
+}
+
+// RelayInfo describes one upstream Icecast/SHOUTcast relay.
+type RelayInfo struct {
+	Name        string     `json:"name"`
+	Source      string     `json:"source"`
+	State       string     `json:"state"` // idle, connecting, connected, backoff, stopped
+	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	BytesSent   int64      `json:"bytesSent"`
+	Reconnects  int        `json:"reconnects"`
+}
+
+// LiveInfo describes a connected live DJ source.
+type LiveInfo struct {
+	Mount     string    `json:"mount"`
+	Name      string    `json:"name,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	OnAir     bool      `json:"onAir"`
+}
+
+// MountInfo describes one stream mount for the status endpoint.
+type MountInfo struct {
+	Path        string `json:"path"`
+	Codec       string `json:"codec"`
+	Bitrate     string `json:"bitrate"`
+	ContentType string `json:"contentType"`
+	Listeners   int    `json:"listeners"`
 }
 
 // StatusSnapshot holds all fields for the GET /api/status response.
@@ -31,6 +104,13 @@ type StatusSnapshot struct {
 	LibraryTracks    int
 	ActiveClients    int
 	MaxClients       int
+	Mounts           []MountInfo
+	FallbackReason   string    // empty unless the fallback source is on air
+	Live             *LiveInfo // nil unless a DJ is connected
+	Relays           []RelayInfo
+	Jingle           *JingleInfo // nil unless a station ID is on air
+	Pause            *PauseInfo  // nil unless the DJ paused the broadcast
+	Progress         *TrackProgress
 	ActiveTag        playlist.TimeTag
 	ActivePlaylist   string
 	ActivePlaylistID *int64
@@ -45,7 +125,11 @@ type SchedulerSnapshot struct {
 	Running       bool
 	LastTag       playlist.TimeTag
 	TimeTags      []playlist.TimeTag
+	Slots         []playlist.TimeSlot
 	CurrentTag    playlist.TimeTag
+	ActiveDay     time.Weekday
+	Override      *playlist.ScheduleOverride // nil when the normal schedule applies
+	OverrideEnds  time.Time
 	Summary       interface{}
 	LibraryTracks int
 	Timezone      string
@@ -68,6 +152,8 @@ type RadioService struct {
 	scheduler   *playlist.Scheduler
 	broadcaster Broadcaster
 	cfg         *config.Config
+	events      *events.Bus
+	prober      playlist.Prober
 }
 
 func NewRadioService(
@@ -76,6 +162,8 @@ func NewRadioService(
 	scheduler *playlist.Scheduler,
 	broadcaster Broadcaster,
 	cfg *config.Config,
+	bus *events.Bus,
+	prober playlist.Prober,
 ) *RadioService {
 	return &RadioService{
 		master:      master,
@@ -83,6 +171,8 @@ func NewRadioService(
 		scheduler:   scheduler,
 		broadcaster: broadcaster,
 		cfg:         cfg,
+		events:      bus,
+		prober:      prober,
 	}
 }
 
@@ -122,6 +212,13 @@ func (s *RadioService) Status() StatusSnapshot {
 				}
 			}
 		}
+	} else if info := s.broadcaster.CurrentTrackInfo(); info != nil {
+		// Not a library track, e.g. metadata sent by a live DJ.
+		currentTrackRaw = info
+		trackName = info.Title
+		if info.Artist != "" {
+			trackName = info.Artist + " - " + info.Title
+		}
 	}
 
 	loc := s.master.Location()
@@ -138,13 +235,20 @@ func (s *RadioService) Status() StatusSnapshot {
 		LibraryTracks:    s.master.LibraryTrackCount(),
 		ActiveClients:    s.broadcaster.ActiveClients(),
 		MaxClients:       s.cfg.MaxClients,
+		Mounts:           s.broadcaster.Mounts(),
+		FallbackReason:   s.broadcaster.FallbackReason(),
+		Live:             s.broadcaster.LiveStatus(),
+		Relays:           s.broadcaster.Relays(),
+		Jingle:           s.broadcaster.CurrentJingle(),
+		Pause:            s.broadcaster.PauseStatus(),
+		Progress:         s.broadcaster.Progress(),
 		ActiveTag:        activeTag,
 		ActivePlaylist:   activePlaylistName,
 		ActivePlaylistID: activePlaylistID,
 		SchedulerRunning: s.scheduler.Running(),
 		PlaylistSummary:  s.master.Summary(),
 		Timezone:         tz,
-		ServerTime:       time.Now().In(loc).Format(time.RFC3339),
+		ServerTime:       s.master.Now().In(loc).Format(time.RFC3339),
 	}
 }
 
@@ -155,15 +259,20 @@ func (s *RadioService) SchedulerStatus() SchedulerSnapshot {
 	if tz == "" {
 		tz = "UTC"
 	}
+	override, overrideEnds := s.master.CurrentOverride()
 	return SchedulerSnapshot{
 		Running:       s.scheduler.Running(),
 		LastTag:       s.scheduler.LastTag(),
-		TimeTags:      playlist.ValidTimeTags,
-		CurrentTag:    playlist.CurrentTimeTagIn(loc),
+		TimeTags:      s.master.SlotNames(),
+		Slots:         s.master.Slots(),
+		CurrentTag:    s.master.CurrentTag(),
+		ActiveDay:     s.master.ActiveDay(),
+		Override:      override,
+		OverrideEnds:  overrideEnds,
 		Summary:       s.master.Summary(),
 		LibraryTracks: s.master.LibraryTrackCount(),
 		Timezone:      tz,
-		ServerTime:    time.Now().In(loc).Format(time.RFC3339),
+		ServerTime:    s.master.Now().In(loc).Format(time.RFC3339),
 	}
 }
 
@@ -211,37 +320,54 @@ func (s *RadioService) GetQueue(n int) []*playlist.Track {
 
 // SkipNext immediately skips to the next track by aborting the current one.
 func (s *RadioService) SkipNext() {
+	s.events.Publish(events.TypeSkipRequested, events.SkipRequested{Direction: "next"})
 	s.broadcaster.Skip()
 }
 
+// Pause freezes the track on air until Resume is called.
+func (s *RadioService) Pause() error {
+	return s.broadcaster.Pause()
+}
+
+// Resume continues the paused track from where it stopped.
+func (s *RadioService) Resume() error {
+	return s.broadcaster.Resume()
+}
+
 // SkipPrev seeks the active playlist cursor back one position, then aborts the
 // current track so playback restarts from the previous track.
 func (s *RadioService) SkipPrev() error {
 	if err := s.master.SeekPrev(); err != nil {
 		return err
 	}
+	s.events.Publish(events.TypeSkipRequested, events.SkipRequested{Direction: "prev"})
 	s.broadcaster.Skip()
 	return nil
 }
 
 // Reconcile scans the music directory, removes stale tracks, auto-adds
-// orphaned tracks to the active playlist, and persists state.
+// orphaned tracks to the active playlist (unless it is a smart playlist,
+// whose rule decides), refreshes smart playlists and persists state.
 func (s *RadioService) Reconcile() (ReconcileResult, error) {
-	orphaned, removedCount, err := playlist.ReconcileTracks(s.cfg.MusicDir, s.master)
+	orphaned, removedCount, err := playlist.ReconcileTracks(s.cfg.MusicDir, s.master, s.prober)
 	if err != nil {
 		return ReconcileResult{}, err
 	}
 	if len(orphaned) > 0 {
 		activePl, plErr := s.master.ActivePlaylist()
-		if plErr == nil && activePl != nil {
+		if plErr == nil && activePl != nil && !activePl.IsSmart() {
 			activePl.AddTracks(orphaned)
 			slog.Info("Added orphaned tracks to active playlist",
 				"count", len(orphaned),
 				"playlist", activePl.Name,
 			)
 		}
+		s.master.RefreshSmartPlaylists()
 	}
 	s.save()
+	if removedCount > 0 || len(orphaned) > 0 {
+		s.events.Publish(events.TypePlaylistModified, events.PlaylistModified{Action: "reconciled"})
+	}
 	return ReconcileResult{
 		RemovedCount:  removedCount,
 		OrphanedCount: len(orphaned),
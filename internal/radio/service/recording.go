@@ -0,0 +1,35 @@
+package service
+
+import (
+	"github.com/arung-agamani/denpa-radio/internal/archive"
+)
+
+// RecordingService implements the business logic for browsing and managing
+// the stream archive.
+type RecordingService struct {
+	archive *archive.Archive
+}
+
+func NewRecordingService(a *archive.Archive) *RecordingService {
+	return &RecordingService{archive: a}
+}
+
+// List returns every recording, newest first.
+func (s *RecordingService) List() ([]archive.Recording, error) {
+	return s.archive.List()
+}
+
+// Get returns one recording with its track list.
+func (s *RecordingService) Get(name string) (archive.Recording, error) {
+	return s.archive.Get(name)
+}
+
+// FilePath returns the on-disk audio file of a recording for download.
+func (s *RecordingService) FilePath(name string) (string, error) {
+	return s.archive.Path(name)
+}
+
+// Delete removes a finished recording.
+func (s *RecordingService) Delete(name string) error {
+	return s.archive.Delete(name)
+}
@@ -16,14 +16,17 @@ import (
 
 // TrackService implements the business logic for track library operations.
 type TrackService struct {
-	master  *playlist.MasterPlaylist
-	store   *playlist.Store
-	cfg     *config.Config
-	encoder *ffmpeg.Encoder
+	master   *playlist.MasterPlaylist
+	store    *playlist.Store
+	cfg      *config.Config
+	encoder  ffmpeg.Encoder
+	loudness *loudnessJob
 }
 
-func NewTrackService(master *playlist.MasterPlaylist, store *playlist.Store, cfg *config.Config, encoder *ffmpeg.Encoder) *TrackService {
-	return &TrackService{master: master, store: store, cfg: cfg, encoder: encoder}
+func NewTrackService(master *playlist.MasterPlaylist, store *playlist.Store, cfg *config.Config, encoder ffmpeg.Encoder) *TrackService {
+	s := &TrackService{master: master, store: store, cfg: cfg, encoder: encoder}
+	s.loudness = newLoudnessJob(master, encoder, s.save)
+	return s
 }
 
 func (s *TrackService) save() {
@@ -32,6 +35,14 @@ func (s *TrackService) save() {
 	}
 }
 
+// refreshSmartPlaylists re-runs the smart playlist rules after the library
+// changed.
+func (s *TrackService) refreshSmartPlaylists() {
+	if n := s.master.RefreshSmartPlaylists(); n > 0 {
+		slog.Info("Smart playlists refreshed", "changed", n)
+	}
+}
+
 // List returns all tracks from the library, or deduplicated from all playlists
 // if the library is not initialised.
 func (s *TrackService) List() []*playlist.Track {
@@ -66,7 +77,7 @@ func (s *TrackService) Search(q string) ([]*playlist.Track, error) {
 
 // ListOrphaned returns tracks present on disk but not registered in any playlist.
 func (s *TrackService) ListOrphaned() ([]*playlist.Track, error) {
-	return playlist.FindOrphanedTracks(s.cfg.MusicDir, s.master)
+	return playlist.FindOrphanedTracks(s.cfg.MusicDir, s.master, s.encoder)
 }
 
 // Update modifies the metadata of a library track by ID.
@@ -79,6 +90,7 @@ func (s *TrackService) Update(id int64, upd playlist.TrackUpdate) (*playlist.Tra
 		return nil, err
 	}
 	slog.Info("Track metadata updated", "track_id", id, "title", track.Title)
+	s.refreshSmartPlaylists()
 	s.save()
 	return track, nil
 }
@@ -123,17 +135,41 @@ func (s *TrackService) Delete(id int64, deleteFromDisk bool) (playlistRemovals i
 }
 
 // Scan re-scans the music directory and registers newly discovered files in
-// the library. Returns (newlyAdded, libraryTotal, error).
-func (s *TrackService) Scan() (int, int, error) {
+// the library. Returns the number of newly added tracks, the library total
+// and the files left out because they failed to probe.
+func (s *TrackService) Scan() (int, int, []*playlist.Track, error) {
 	if s.master.Library == nil {
-		return 0, 0, fmt.Errorf("track library not initialised")
+		return 0, 0, nil, fmt.Errorf("track library not initialised")
 	}
-	_, added, err := playlist.ScanIntoLibrary(s.cfg.MusicDir, s.master.Library)
+	result, added, err := playlist.ScanIntoLibrary(s.cfg.MusicDir, s.master.Library, s.encoder)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, nil, err
 	}
+	s.refreshSmartPlaylists()
 	s.save()
-	return added, s.master.Library.Count(), nil
+	s.loudness.enqueue(result.Tracks, false)
+	return added, s.master.Library.Count(), result.Corrupt, nil
+}
+
+// RunLoudnessAnalysis processes queued loudness analyses until ctx is
+// cancelled.
+func (s *TrackService) RunLoudnessAnalysis(ctx context.Context) {
+	s.loudness.run(ctx)
+}
+
+// AnalyzeLoudness queues every library track without a loudness measurement
+// (or every track, when force is set) for background analysis. Returns the
+// number of tracks queued.
+func (s *TrackService) AnalyzeLoudness(force bool) (int, error) {
+	if s.master.Library == nil {
+		return 0, fmt.Errorf("track library not initialised")
+	}
+	return s.loudness.enqueue(s.master.Library.List(), force), nil
+}
+
+// LoudnessStatus reports the progress of the background loudness analysis.
+func (s *TrackService) LoudnessStatus() LoudnessStatus {
+	return s.loudness.snapshot()
 }
 
 // LibraryTotal returns the number of tracks currently in the library.
@@ -190,7 +226,8 @@ func uniqueDestPath(dir, filename string) string {
 
 // Upload saves the provided audio content to the music directory under the
 // given filename, registers it in the track library, and persists state.
-// Returns an error if the extension is unsupported or if any I/O fails.
+// Returns an error if the extension is unsupported, the file fails to probe
+// (it is then deleted again) or if any I/O fails.
 //
 // Collision avoidance: if a file with the chosen name already exists on disk
 // (regardless of content) a numbered suffix is appended before writing, so
@@ -278,6 +315,15 @@ func (s *TrackService) Upload(filename string, content io.Reader, meta UploadMet
 		return nil, fmt.Errorf("failed to read audio metadata: %w", err)
 	}
 
+	// Refuse files ffprobe cannot read rather than adding an unplayable
+	// track to the library.
+	if s.encoder != nil {
+		if err := playlist.ProbeTrack(context.Background(), track, s.encoder); err != nil {
+			os.Remove(dest)
+			return nil, fmt.Errorf("corrupt audio file: %w", err)
+		}
+	}
+
 	// Apply caller-supplied metadata overrides before registering in the library
 	// so that whatever is stored is already correct.
 	if meta.Title != "" {
@@ -326,7 +372,9 @@ func (s *TrackService) Upload(filename string, content io.Reader, meta UploadMet
 			"track_id", canonical.ID,
 			"title", canonical.Title,
 		)
+		s.refreshSmartPlaylists()
 		s.save()
+		s.loudness.enqueue([]*playlist.Track{canonical}, false)
 	} else {
 		// Duplicate – remove the file we just wrote since the library already
 		// knows this checksum (possibly under a different filename).
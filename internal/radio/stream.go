@@ -2,48 +2,182 @@ package radio
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/arung-agamani/denpa-radio/internal/events"
 	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/history"
+	"github.com/arung-agamani/denpa-radio/internal/listener"
 	"github.com/arung-agamani/denpa-radio/internal/playlist"
+	"github.com/arung-agamani/denpa-radio/internal/radio/service"
 )
 
+// streamChunk is one unit of encoded audio queued for a subscriber. For MP3
+// and AAC it holds whole frames only.
+type streamChunk struct {
+	data     []byte
+	frames   int           // number of whole frames in data; 0 if unknown
+	duration time.Duration // playback time covered; 0 if unknown
+
+	// discontinuity marks the first chunk of a restarted encoder, whose
+	// stream does not continue the previous one.
+	discontinuity bool
+
+	// burst marks the catch-up audio sent on connect. The client is meant
+	// to take it in at once, so it is left out of the backlog.
+	burst bool
+}
+
 // clientSub represents a single subscribed listener.
 type clientSub struct {
-	ch chan []byte
-	id uint64
+	ch    chan streamChunk
+	id    uint64
+	mount *mount
+	// internal marks subscribers run by the server itself (HLS segmenter,
+	// …); they are not counted as listeners.
+	internal bool
+
+	// dropped counts frames discarded because the channel was full, and
+	// backlog the live playback time queued but not yet consumed
+	// (nanoseconds); the burst sent on connect does not count.
+	// Both are updated under Broadcaster.mu read lock, hence atomic.
+	dropped atomic.Uint64
+	backlog atomic.Int64
+
+	// kicked is closed when the slow-client policy disconnects the client.
+	kicked   chan struct{}
+	kickOnce sync.Once
+}
+
+// consumed must be called by the reader for every chunk taken from ch so
+// the backlog stays accurate.
+func (s *clientSub) consumed(c streamChunk) {
+	if !c.burst {
+		s.backlog.Add(-int64(c.duration))
+	}
+}
+
+// Kicked is closed once the client has been disconnected by the
+// broadcaster.
+func (s *clientSub) Kicked() <-chan struct{} {
+	return s.kicked
+}
+
+func (s *clientSub) kick() {
+	s.kickOnce.Do(func() { close(s.kicked) })
+}
+
+// SlowClientPolicy decides when a listener that cannot keep up is
+// disconnected instead of having audio dropped indefinitely. Zero values
+// disable the respective limit.
+type SlowClientPolicy struct {
+	// MaxDroppedFrames disconnects a client once this many frames have been
+	// dropped for it in total.
+	MaxDroppedFrames int
+	// MaxBacklog disconnects a client whose queued, unsent audio exceeds
+	// this much playback time. Opus chunks carry no duration, so it does
+	// not apply to Opus mounts.
+	MaxBacklog time.Duration
 }
 
 // Broadcaster runs a single, continuous ffmpeg encoding pipeline and fans the
-// resulting MP3 chunks out to every connected HTTP client.  It keeps playing
-// (advancing the playlist) even when zero clients are connected.
+// resulting chunks out to every connected HTTP client.  Tracks are decoded to
+// PCM one after another and fed into one long-lived encoder per mount,
+// optionally crossfaded, so every mount shares the same playout position.  It
+// keeps playing (advancing the playlist) even when zero clients are connected.
 type Broadcaster struct {
 	legacyPlaylist *Playlist
 	masterPlaylist *playlist.MasterPlaylist
-	encoder        *ffmpeg.Encoder
+	encoder        ffmpeg.Encoder
 
 	mu           sync.RWMutex
-	clients      map[uint64]*clientSub
+	mounts       []*mount
 	nextID       uint64
 	currentTrack atomic.Value // stores string (file path)
+	currentInfo  atomic.Value // stores *playlist.Track (metadata for ICY/now-playing)
+
+	// crossfade is the station-wide overlap between tracks, in seconds.
+	crossfade float64
+
+	// burst is how much recent audio each mount keeps for new listeners.
+	burst time.Duration
+
+	// slowPolicy governs when lagging listeners are disconnected.
+	slowPolicy SlowClientPolicy
+
+	// loudnessTarget is the integrated loudness (LUFS) tracks are normalised
+	// to; zero disables normalisation.
+	loudnessTarget float64
+
+	// fallback is played when no track can be played; fallbackReason holds
+	// why it is on air (string, empty during normal playout).
+	fallback       FallbackSource
+	fallbackReason atomic.Value
+
+	// live is the connected DJ source, nil during automation.
+	live *LiveInput
+
+	// relays push the broadcast to upstream servers; listed for status.
+	relays []*Relay
+
+	// cursor is the regular track on air (*playCursor, nil otherwise);
+	// checkpointStore and checkpointEvery control how often its position
+	// is saved.
+	cursor          atomic.Value
+	checkpointStore *playlist.Store
+	checkpointEvery time.Duration
+
+	// jingles holds the station-ID pool and insertion rules;
+	// currentJingle is the jingle on air (*playlist.Track, nil otherwise).
+	jingles       jingleState
+	currentJingle atomic.Value
+
+	// progress follows how far listeners are into the track on air
+	// (*trackProgress, nil for jingles and the fallback).
+	progress atomic.Value
+
+	// pause holds the track on air while the DJ has paused the broadcast.
+	pause pauseState
+
+	// history logs every regular track played; nil disables it.
+	history *history.Log
+
+	// events receives track changes and listener counts; nil discards them.
+	events *events.Bus
 
 	// skipCh is signalled by Skip() to abort the current track and advance.
 	skipCh chan struct{}
+
+	// pipelines counts the encoder pipelines started so far; only touched
+	// by the Start goroutine.
+	pipelines int
 }
 
-func NewBroadcaster(legacyPlaylist *Playlist, encoder *ffmpeg.Encoder) *Broadcaster {
+func NewBroadcaster(legacyPlaylist *Playlist, encoder ffmpeg.Encoder) *Broadcaster {
 	b := &Broadcaster{
 		legacyPlaylist: legacyPlaylist,
 		encoder:        encoder,
-		clients:        make(map[uint64]*clientSub),
-		skipCh:         make(chan struct{}, 1),
+		mounts: []*mount{newMount(MountConfig{
+			Path:   DefaultMountPath,
+			Format: ffmpeg.OutputFormat{Codec: ffmpeg.CodecMP3},
+		})},
+		fallback: FallbackSource{Kind: FallbackSilence},
+		skipCh:   make(chan struct{}, 1),
 	}
 	b.currentTrack.Store("")
+	b.currentInfo.Store((*playlist.Track)(nil))
+	b.fallbackReason.Store("")
+	b.progress.Store((*trackProgress)(nil))
 	return b
 }
 
@@ -55,94 +189,366 @@ func (b *Broadcaster) SetMasterPlaylist(master *playlist.MasterPlaylist) {
 	b.masterPlaylist = master
 }
 
-// nextTrack returns the file path of the next track to play. It prefers the
-// MasterPlaylist if available, falling back to the legacy Playlist.
-func (b *Broadcaster) nextTrack() (string, bool) {
+// master returns the MasterPlaylist, or nil in legacy mode.
+func (b *Broadcaster) master() *playlist.MasterPlaylist {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.masterPlaylist
+}
+
+// SetMounts replaces the set of mounts the broadcaster encodes for. It must
+// be called before Start.
+func (b *Broadcaster) SetMounts(configs []MountConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	mounts := make([]*mount, 0, len(configs))
+	for _, cfg := range configs {
+		mounts = append(mounts, newMount(cfg))
+	}
+	b.mounts = mounts
+}
+
+// nextTrack returns the next track to play and the playlist it came from. It
+// prefers the MasterPlaylist if available, falling back to the legacy
+// Playlist. Tracks coming from the legacy playlist are wrapped in a minimal
+// playlist.Track (with a nil playlist) so callers always have metadata.
+// Between tracks a station ID from the jingle pool may be returned instead;
+// its Jingle flag is set.
+func (b *Broadcaster) nextTrack() (*playlist.Track, *playlist.Playlist, bool) {
+	if jingle := b.dueJingle(); jingle != nil {
+		return jingle, nil, true
+	}
+
 	b.mu.RLock()
 	master := b.masterPlaylist
 	b.mu.RUnlock()
 
 	if master != nil {
-		track, _, err := master.Next()
+		track, pl, err := master.Next()
 		if err != nil {
 			slog.Warn("MasterPlaylist.Next() error", "error", err)
-			return "", false
+			return nil, nil, false
 		}
 		if track == nil {
-			return "", false
+			return nil, nil, false
 		}
-		return track.FilePath, true
+		return track, pl, true
 	}
 
 	// Legacy fallback.
 	if b.legacyPlaylist != nil {
-		return b.legacyPlaylist.Next()
+		path, ok := b.legacyPlaylist.Next()
+		if !ok {
+			return nil, nil, false
+		}
+		track := &playlist.Track{FilePath: path, Title: filepath.Base(path)}
+		if info, found := b.legacyPlaylist.GetTrackInfo(path); found {
+			track.Title = info.Title
+			track.Artist = info.Artist
+			track.Album = info.Album
+		}
+		return track, nil, true
 	}
 
-	return "", false
+	return nil, nil, false
 }
 
+// skipFadeOut is the length of the fade applied when a track is skipped.
+const skipFadeOut = 50 * time.Millisecond
+
 // Start begins the continuous broadcast loop.  It blocks until ctx is
 // cancelled.
 func (b *Broadcaster) Start(ctx context.Context) {
 	slog.Info("Broadcaster started")
+	b.warnUnframedMounts()
+	go b.runCheckpoints(ctx)
 	for {
+		err := b.runPipeline(ctx)
+		if ctx.Err() != nil {
+			slog.Info("Broadcaster stopping")
+			return
+		}
+		slog.Error("Broadcast pipeline stopped, restarting", "error", err)
 		select {
+		case <-time.After(1 * time.Second):
 		case <-ctx.Done():
 			slog.Info("Broadcaster stopping")
 			return
+		}
+	}
+}
+
+// warnUnframedMounts logs the mounts whose codec the broadcaster cannot
+// split into frames (Opus in Ogg). Their audio is relayed as it comes, so
+// they get no burst and their backlog is not measured.
+func (b *Broadcaster) warnUnframedMounts() {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.burst <= 0 && b.slowPolicy.MaxBacklog <= 0 {
+		return
+	}
+	for _, m := range b.mounts {
+		if newFrameSplitter(m.cfg.Format.Codec) == nil {
+			slog.Warn("Burst and slow-client backlog limit do not apply to this mount",
+				"mount", m.cfg.Path,
+				"codec", m.cfg.Format.Codec,
+			)
+		}
+	}
+}
+
+// runPipeline starts one long-lived encoder per mount and feeds them the
+// decoded PCM of consecutive tracks, so listeners hear a single continuous
+// stream. It returns when ctx is cancelled or any encoder process dies.
+func (b *Broadcaster) runPipeline(ctx context.Context) error {
+	pipeCtx, pipeCancel := context.WithCancel(ctx)
+	defer pipeCancel()
+
+	b.mu.RLock()
+	mounts := b.mounts
+	b.mu.RUnlock()
+
+	encoders := make([]ffmpeg.LiveEncoder, 0, len(mounts))
+	defer func() {
+		pipeCancel()
+		for _, enc := range encoders {
+			<-enc.Done()
+		}
+	}()
+
+	// After a restart the new encoders start a fresh stream; the first
+	// chunk of each is flagged so HLS can mark the discontinuity.
+	b.pipelines++
+	restarted := b.pipelines > 1
+
+	pcmOuts := make([]io.Writer, 0, len(mounts))
+	encDone := make(chan error, len(mounts))
+	for _, m := range mounts {
+		m.resetHeader()
+		out := &broadcastWriter{broadcaster: b, mount: m, frames: newFrameSplitter(m.cfg.Format.Codec), discontinuity: restarted}
+		enc, err := b.encoder.StartLive(pipeCtx, m.cfg.Format, out)
+		if err != nil {
+			return fmt.Errorf("mount %s: %w", m.cfg.Path, err)
+		}
+		encoders = append(encoders, enc)
+		pcmOuts = append(pcmOuts, enc)
+		go func(path string, enc ffmpeg.LiveEncoder) {
+			<-enc.Done()
+			if err := enc.Err(); err != nil {
+				encDone <- fmt.Errorf("mount %s: %w", path, err)
+				return
+			}
+			encDone <- nil
+		}(m.cfg.Path, enc)
+	}
+
+	cutBytes := int(skipFadeOut.Seconds() * float64(b.encoder.BytesPerSecond()))
+	pcmOut := newPacedWriter(io.MultiWriter(pcmOuts...), b.encoder.BytesPerSecond())
+	mixer := newCrossfader(pcmOut, b.encoder.FrameBytes(), cutBytes)
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-encDone:
+			return err
 		default:
 		}
 
-		track, ok := b.nextTrack()
+		// A connected DJ takes precedence over the automation.
+		if li := b.takeLive(); li != nil {
+			if err := b.playLive(ctx, mixer, li); err != nil {
+				return err
+			}
+			continue
+		}
+
+		track, pl, ok := b.nextTrack()
 		if !ok {
-			slog.Warn("Playlist empty, waiting before retry")
-			select {
-			case <-time.After(2 * time.Second):
-				continue
-			case <-ctx.Done():
-				return
+			// Keep audio flowing so connected players do not give up.
+			if err := b.playFallback(ctx, mixer, "no playable track in the active playlist"); err != nil {
+				return err
 			}
+			continue
+		}
+
+		trackName := filepath.Base(track.FilePath)
+		if track.Jingle {
+			// Keep the song title on the dashboard and in ICY metadata.
+			b.currentJingle.Store(track)
+			slog.Info("Broadcasting jingle", "jingle", trackName)
+		} else {
+			b.currentJingle.Store((*playlist.Track)(nil))
+			b.currentTrack.Store(track.FilePath)
+			b.currentInfo.Store(track)
+			slog.Info("Broadcasting track", "track", trackName)
 		}
 
-		trackName := filepath.Base(track)
-		b.currentTrack.Store(track)
-		slog.Info("Broadcasting track", "track", trackName)
+		mixer.StartTrack(b.crossfadeBytes(pl))
 
 		// Create a per-track context so we can abort just this track on skip.
 		trackCtx, trackCancel := context.WithCancel(ctx)
+		skipWatch := b.watchSkip(trackCtx, trackCancel)
+
+		out := &pauseGate{ctx: trackCtx, broadcaster: b, mixer: mixer, out: pcmOut}
+		start := b.startCursor(track, pl, mixer)
+		if track.Jingle {
+			b.clearProgress()
+		} else {
+			startedAt := b.startProgress(track, mixer, start)
+			b.publish(events.TypeTrackStarted, events.TrackStarted{Track: track, Playlist: pl, StartedAt: startedAt, Offset: start})
+		}
+		opts := ffmpeg.DecodeOptions{GainDB: b.trackGain(track), Start: start}
 
-		// Goroutine that listens for a skip signal and cancels the track context.
-		done := make(chan struct{})
-		go func() {
-			defer close(done)
-			select {
-			case <-b.skipCh:
-				trackCancel()
-			case <-trackCtx.Done():
-			}
-		}()
-
-		writer := &broadcastWriter{broadcaster: b}
-		err := b.encoder.Stream(trackCtx, track, writer)
+		// The fallback ends as soon as the track actually produces audio.
+		err := b.encoder.Decode(trackCtx, track.FilePath, opts, &firstWriteHook{w: out, hook: b.endFallback})
 		trackCancel()
-		<-done // wait for the skip-watcher goroutine to exit
-
-		if err != nil {
-			if ctx.Err() != nil {
-				// Main context cancelled – shut down.
-				return
+		skipped := <-skipWatch // wait for the skip watcher to exit
+		b.currentJingle.Store((*playlist.Track)(nil))
+
+		if ctx.Err() != nil {
+			// Main context cancelled – shut down. The cursor is kept so the
+			// final checkpoint records where the track was interrupted.
+			b.recordPlay(skipped, 0)
+			return nil
+		}
+		if skipped {
+			// Track was skipped – fade out what is held back and advance to
+			// the next one immediately, without a crossfade.
+			failures = 0
+			cutErr := mixer.Cut()
+			b.recordPlay(true, 0)
+			b.cursor.Store((*playCursor)(nil))
+			if cutErr != nil {
+				return cutErr
 			}
-			if trackCtx.Err() != nil {
-				// Track was skipped – advance to the next one immediately.
-				continue
+			continue
+		}
+		endErr := mixer.EndTrack()
+		b.recordPlay(false, mixer.outroBytes())
+		b.cursor.Store((*playCursor)(nil))
+		if endErr != nil {
+			return endErr
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		slog.Error("Broadcast decoding error", "error", err, "track", trackName)
+		failures++
+		if failures >= maxConsecutiveFailures {
+			reason := fmt.Sprintf("%d consecutive tracks failed to decode", failures)
+			if err := b.playFallback(ctx, mixer, reason); err != nil {
+				return err
 			}
-			slog.Error("Broadcast encoding error", "error", err, "track", trackName)
-			// Small pause before trying the next track so we don't spin on a
-			// persistently broken file.
-			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		// Small pause before trying the next track so we don't spin on a
+		// persistently broken file.
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// firstWriteHook calls hook once, on the first non-empty write.
+type firstWriteHook struct {
+	w    io.Writer
+	hook func()
+	done bool
+}
+
+func (fw *firstWriteHook) Write(p []byte) (int, error) {
+	if !fw.done && len(p) > 0 {
+		fw.done = true
+		fw.hook()
+	}
+	return fw.w.Write(p)
+}
+
+// SetEvents makes the broadcaster publish track changes and listener
+// counts to bus.
+func (b *Broadcaster) SetEvents(bus *events.Bus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = bus
+}
+
+// SetCrossfade sets the station-wide crossfade length between tracks.
+// Playlists may override it with their own setting. Zero means a gapless
+// join without overlap.
+func (b *Broadcaster) SetCrossfade(seconds float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.crossfade = seconds
+}
+
+// SetBurst sets how much recently encoded audio is sent to a listener the
+// moment it connects, so playback starts without waiting for the player's
+// buffer to fill in real time. Zero disables the burst. Only MP3 and AAC
+// mounts keep a burst; Opus mounts start at the live edge.
+func (b *Broadcaster) SetBurst(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.burst = d
+}
+
+// SetSlowClientPolicy sets when listeners that fall behind are
+// disconnected.
+func (b *Broadcaster) SetSlowClientPolicy(p SlowClientPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.slowPolicy = p
+}
+
+// loudnessPeakCeiling is the highest true peak (dBTP) normalisation may push
+// a track to; the gain is reduced rather than clipping.
+const loudnessPeakCeiling = -1.0
+
+// SetLoudnessTarget sets the integrated loudness, in LUFS, that analysed
+// tracks are normalised to. Zero disables normalisation.
+func (b *Broadcaster) SetLoudnessTarget(lufs float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.loudnessTarget = lufs
+}
+
+// trackGain returns the gain in dB that brings t to the loudness target, or
+// zero if normalisation is off or t has not been analysed.
+func (b *Broadcaster) trackGain(t *playlist.Track) float64 {
+	b.mu.RLock()
+	target := b.loudnessTarget
+	b.mu.RUnlock()
+
+	if target == 0 || t.Loudness == nil {
+		return 0
+	}
+	gain := target - *t.Loudness
+	if t.TruePeak != nil {
+		if limit := loudnessPeakCeiling - *t.TruePeak; gain > limit {
+			gain = limit
+		}
+	}
+	return gain
+}
+
+// crossfadeBytes returns the crossfade length in PCM bytes for a track from
+// the given playlist.
+func (b *Broadcaster) crossfadeBytes(pl *playlist.Playlist) int {
+	b.mu.RLock()
+	seconds := b.crossfade
+	b.mu.RUnlock()
+
+	if pl != nil {
+		if v, ok := pl.Crossfade(); ok {
+			seconds = v
 		}
 	}
+	if seconds <= 0 {
+		return 0
+	}
+	frames := int(seconds * float64(b.encoder.SampleRateHz()))
+	return frames * b.encoder.FrameBytes()
 }
 
 // Skip aborts the currently-streaming track and immediately advances to the
@@ -161,23 +567,75 @@ func (b *Broadcaster) CurrentTrack() string {
 	return v
 }
 
-// Subscribe adds a new listener and returns the subscription.  The caller must
-// call Unsubscribe when done.
-func (b *Broadcaster) Subscribe() *clientSub {
+// CurrentTrackInfo returns the metadata of the track currently being
+// broadcast, or nil if nothing has played yet.
+func (b *Broadcaster) CurrentTrackInfo() *playlist.Track {
+	t, _ := b.currentInfo.Load().(*playlist.Track)
+	return t
+}
+
+// StreamTitle returns the "Artist - Title" string advertised to ICY clients
+// for the current track. It is empty when nothing is playing.
+func (b *Broadcaster) StreamTitle() string {
+	return formatStreamTitle(b.CurrentTrackInfo())
+}
+
+// mountUnsafe returns the mount serving path, or nil. The caller must hold
+// b.mu.
+func (b *Broadcaster) mountUnsafe(path string) *mount {
+	for _, m := range b.mounts {
+		if m.cfg.Path == path {
+			return m
+		}
+	}
+	return nil
+}
+
+// Subscribe adds a new listener to the given mount and returns the
+// subscription.  The caller must call Unsubscribe when done.
+func (b *Broadcaster) Subscribe(mountPath string) (*clientSub, error) {
+	return b.subscribe(mountPath, false)
+}
+
+// subscribe adds a subscriber to the given mount. Internal subscribers
+// receive the same audio but are left out of listener counts.
+func (b *Broadcaster) subscribe(mountPath string, internal bool) (*clientSub, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	m := b.mountUnsafe(mountPath)
+	if m == nil {
+		return nil, fmt.Errorf("mount %q not found", mountPath)
+	}
+
 	id := b.nextID
 	b.nextID++
 
 	sub := &clientSub{
 		// Buffered channel so the broadcaster doesn't block on a single slow
 		// client.  If the buffer fills up we drop chunks for that client.
-		ch: make(chan []byte, 512),
-		id: id,
+		ch:       make(chan streamChunk, 512),
+		id:       id,
+		mount:    m,
+		internal: internal,
+		kicked:   make(chan struct{}),
+	}
+	if header := m.streamHeader(); header != nil {
+		sub.ch <- streamChunk{data: header}
 	}
-	b.clients[id] = sub
-	return sub
+	// Holding b.mu keeps broadcastWriter from adding frames between the
+	// snapshot and registration, so the burst joins the live feed seamlessly.
+	// The burst is left out of the backlog: counted, it would eat into the
+	// slow-client limit, or exceed it outright when the burst is longer.
+	if burst := m.burstSnapshot(); burst.data != nil {
+		burst.burst = true
+		sub.ch <- burst
+	}
+	m.clients[id] = sub
+	if !internal {
+		b.listenersChangedUnsafe(m)
+	}
+	return sub, nil
 }
 
 // Unsubscribe removes a listener.
@@ -185,16 +643,67 @@ func (b *Broadcaster) Unsubscribe(sub *clientSub) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	delete(b.clients, sub.id)
+	delete(sub.mount.clients, sub.id)
 	// Drain channel so any pending write in broadcastWriter doesn't block.
 	close(sub.ch)
+	if !sub.internal {
+		b.listenersChangedUnsafe(sub.mount)
+	}
+}
+
+// listenersChangedUnsafe publishes the new listener counts after a listener
+// joined or left m. The caller must hold b.mu.
+func (b *Broadcaster) listenersChangedUnsafe(m *mount) {
+	total := 0
+	for _, other := range b.mounts {
+		total += other.listenersUnsafe()
+	}
+	b.events.Publish(events.TypeListeners, events.ListenersChanged{
+		Total:          total,
+		Mount:          m.cfg.Path,
+		MountListeners: m.listenersUnsafe(),
+	})
+}
+
+// publish sends an event to the bus set with SetEvents, if any.
+func (b *Broadcaster) publish(t events.Type, data any) {
+	b.mu.RLock()
+	bus := b.events
+	b.mu.RUnlock()
+	bus.Publish(t, data)
 }
 
-// ActiveClients returns the number of currently connected listeners.
+// ActiveClients returns the number of currently connected listeners across
+// all mounts.
 func (b *Broadcaster) ActiveClients() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return len(b.clients)
+	total := 0
+	for _, m := range b.mounts {
+		total += m.listenersUnsafe()
+	}
+	return total
+}
+
+// Mounts describes every configured mount and its listener count.
+func (b *Broadcaster) Mounts() []service.MountInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	infos := make([]service.MountInfo, 0, len(b.mounts))
+	for _, m := range b.mounts {
+		bitrate := m.cfg.Format.Bitrate
+		if bitrate == "" {
+			bitrate = b.encoder.Bitrate()
+		}
+		infos = append(infos, service.MountInfo{
+			Path:        m.cfg.Path,
+			Codec:       string(m.cfg.Format.Codec),
+			Bitrate:     bitrate,
+			ContentType: m.cfg.Format.Codec.ContentType(),
+			Listeners:   m.listenersUnsafe(),
+		})
+	}
+	return infos
 }
 
 // ReloadPlaylist triggers a hot-reload of the legacy playlist, preserving
@@ -209,11 +718,18 @@ func (b *Broadcaster) ReloadPlaylist() error {
 
 // ---------------------------------------------------------------------------
 // broadcastWriter implements io.Writer and fans every Write call out to all
-// subscribed clients.
+// clients subscribed to one mount.  For MP3 and AAC the output is regrouped
+// into whole frames first, so every chunk starts on a frame boundary.
 // ---------------------------------------------------------------------------
 
 type broadcastWriter struct {
 	broadcaster *Broadcaster
+	mount       *mount
+	frames      *frameSplitter // nil for codecs without frame parsing
+
+	// discontinuity is set until the first chunk of a restarted encoder
+	// has been sent.
+	discontinuity bool
 }
 
 func (w *broadcastWriter) Write(p []byte) (int, error) {
@@ -221,35 +737,105 @@ func (w *broadcastWriter) Write(p []byte) (int, error) {
 	chunk := make([]byte, len(p))
 	copy(chunk, p)
 
+	w.mount.captureHeader(chunk)
+
+	sc := streamChunk{data: chunk}
+	var frames []audioFrame
+	if w.frames != nil {
+		frames = w.frames.Split(chunk)
+		if len(frames) == 0 {
+			return len(p), nil
+		}
+		sc = chunkFromFrames(frames)
+	}
+	sc.discontinuity = w.discontinuity
+	w.discontinuity = false
+
 	w.broadcaster.mu.RLock()
 	defer w.broadcaster.mu.RUnlock()
 
-	for _, sub := range w.broadcaster.clients {
+	if frames != nil {
+		w.mount.pushBurst(frames, w.broadcaster.burst)
+	}
+
+	policy := w.broadcaster.slowPolicy
+	for _, sub := range w.mount.clients {
 		select {
-		case sub.ch <- chunk:
+		case sub.ch <- sc:
+			sub.backlog.Add(int64(sc.duration))
 		default:
 			// Client channel full – drop this chunk for that client to avoid
-			// blocking the entire broadcast.
+			// blocking the entire broadcast.  Chunks hold whole frames, so
+			// the client skips ahead rather than receiving a torn frame.
+			dropped := uint64(sc.frames)
+			if dropped == 0 {
+				dropped = 1
+			}
+			sub.dropped.Add(dropped)
 		}
+		w.applyPolicy(sub, policy)
 	}
 
 	return len(p), nil
 }
 
+// applyPolicy disconnects sub if it has violated the slow-client policy.
+func (w *broadcastWriter) applyPolicy(sub *clientSub, policy SlowClientPolicy) {
+	if sub.internal {
+		return
+	}
+	dropped := sub.dropped.Load()
+	backlog := time.Duration(sub.backlog.Load())
+
+	var reason string
+	switch {
+	case policy.MaxDroppedFrames > 0 && dropped >= uint64(policy.MaxDroppedFrames):
+		reason = "too_many_dropped_frames"
+	case policy.MaxBacklog > 0 && backlog > policy.MaxBacklog:
+		reason = "backlog_exceeded"
+	default:
+		return
+	}
+
+	select {
+	case <-sub.kicked:
+		return // already disconnected
+	default:
+	}
+	slog.Warn("Disconnecting slow client",
+		"mount", w.mount.cfg.Path,
+		"reason", reason,
+		"dropped_frames", dropped,
+		"backlog", backlog,
+	)
+	sub.kick()
+}
+
 // ---------------------------------------------------------------------------
-// StreamHandler serves the /stream endpoint.  Each request subscribes to the
-// Broadcaster and relays chunks to the HTTP response.
+// StreamHandler serves one mount endpoint (e.g. /stream).  Each request
+// subscribes to the Broadcaster, relays chunks to the HTTP response and is
+// recorded as a listener session.
 // ---------------------------------------------------------------------------
 
 type StreamHandler struct {
 	broadcaster *Broadcaster
+	sessions    *listener.Tracker
+	mount       MountConfig
+	bitrate     string
 	stationName string
 	maxClients  int32
 }
 
-func NewStreamHandler(broadcaster *Broadcaster, stationName string, maxClients int) *StreamHandler {
+func NewStreamHandler(broadcaster *Broadcaster, sessions *listener.Tracker, mount MountConfig, stationName string, maxClients int) *StreamHandler {
+	bitrate := mount.Format.Bitrate
+	if bitrate == "" {
+		bitrate = broadcaster.encoder.Bitrate()
+	}
 	return &StreamHandler{
 		broadcaster: broadcaster,
+		sessions:    sessions,
+		mount:       mount,
+		bitrate:     strings.TrimSuffix(strings.ToLower(bitrate), "k"),
 		stationName: stationName,
 		maxClients:  int32(maxClients),
 	}
@@ -265,38 +851,78 @@ func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	clientIP := r.RemoteAddr
-	sub := h.broadcaster.Subscribe()
-	slog.Info("Client connected", "ip", clientIP, "active_clients", h.broadcaster.ActiveClients())
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+	sub, err := h.broadcaster.Subscribe(h.mount.Path)
+	if err != nil {
+		http.Error(w, "Mount not available", http.StatusNotFound)
+		return
+	}
+	session := h.sessions.Start(clientIP, r.UserAgent(), h.mount.Path, sub.kick)
+	slog.Info("Client connected",
+		"ip", clientIP,
+		"mount", h.mount.Path,
+		"session_id", session.ID(),
+		"active_clients", h.broadcaster.ActiveClients(),
+	)
 
 	defer func() {
 		h.broadcaster.Unsubscribe(sub)
-		slog.Info("Client disconnected", "ip", clientIP, "active_clients", h.broadcaster.ActiveClients())
+		session.SetDropped(sub.dropped.Load())
+		session.End()
+		slog.Info("Client disconnected",
+			"ip", clientIP,
+			"mount", h.mount.Path,
+			"session_id", session.ID(),
+			"dropped_frames", sub.dropped.Load(),
+			"active_clients", h.broadcaster.ActiveClients(),
+		)
 	}()
 
-	// Set response headers for an infinite MP3 stream.
-	w.Header().Set("Content-Type", "audio/mpeg")
+	// Clients that understand in-band metadata (mpv, VLC, foobar2000, …)
+	// announce it with "Icy-MetaData: 1". Only those get metadata blocks
+	// interleaved into the audio; everyone else receives plain audio. Ogg
+	// streams carry their own tags, so ICY metadata would corrupt them.
+	wantsMeta := r.Header.Get("Icy-MetaData") == "1" && h.mount.Format.Codec != ffmpeg.CodecOpus
+
+	// Set response headers for an infinite audio stream.
+	w.Header().Set("Content-Type", h.mount.Format.Codec.ContentType())
 	w.Header().Set("Transfer-Encoding", "chunked")
 	w.Header().Set("icy-name", h.stationName)
-	w.Header().Set("icy-br", "128")
+	w.Header().Set("icy-br", h.bitrate)
 	w.Header().Set("Cache-Control", "no-cache, no-store")
 	w.Header().Set("Connection", "keep-alive")
+	if wantsMeta {
+		w.Header().Set("icy-metaint", strconv.Itoa(icyMetaInt))
+	}
 
 	flusher, canFlush := w.(http.Flusher)
 	ctx := r.Context()
 
+	var out io.Writer = w
+	if wantsMeta {
+		out = newICYWriter(w, icyMetaInt, h.broadcaster.StreamTitle)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-sub.Kicked():
+			return
 		case chunk, ok := <-sub.ch:
 			if !ok {
 				// Channel was closed (unsubscribed).
 				return
 			}
-			if _, err := w.Write(chunk); err != nil {
+			sub.consumed(chunk)
+			if _, err := out.Write(chunk.data); err != nil {
 				// Client gone (broken pipe, etc.).
 				return
 			}
+			session.AddBytes(len(chunk.data))
+			session.SetDropped(sub.dropped.Load())
 			if canFlush {
 				flusher.Flush()
 			}
@@ -0,0 +1,174 @@
+package radio
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arung-agamani/denpa-radio/internal/ffmpeg"
+	"github.com/arung-agamani/denpa-radio/internal/history"
+	"github.com/arung-agamani/denpa-radio/internal/playlist"
+)
+
+// newTestBroadcaster returns a broadcaster on a FakeEncoder that plays the
+// given files, in order, from a morning playlist. Start still has to be
+// called.
+func newTestBroadcaster(t *testing.T, files ...string) (*Broadcaster, *ffmpeg.FakeEncoder) {
+	t.Helper()
+	enc := ffmpeg.NewFakeEncoder("128k", 44100, 2)
+
+	master := playlist.NewMasterPlaylist()
+	master.SetClock(playlist.NewFakeClock(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)))
+	pl := playlist.NewPlaylist("Morning", playlist.TagMorning)
+	for i, f := range files {
+		pl.AddTrack(&playlist.Track{ID: int64(i + 1), Title: f, FilePath: f, Checksum: f})
+	}
+	if err := master.AssignPlaylist(playlist.TagMorning, pl); err != nil {
+		t.Fatal(err)
+	}
+	master.ResolveActiveTag()
+
+	b := NewBroadcaster(nil, enc)
+	b.SetMasterPlaylist(master)
+	return b, enc
+}
+
+// runBroadcaster starts b and stops it when the test ends.
+func runBroadcaster(t *testing.T, b *Broadcaster) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("broadcaster did not stop")
+		}
+	})
+}
+
+// waitFor polls cond until it holds or the timeout passes.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBroadcasterPlaysPlaylistInOrder(t *testing.T) {
+	b, enc := newTestBroadcaster(t, "a.mp3", "b.mp3")
+	enc.SetDefaultDuration(0.3)
+
+	sub, err := b.Subscribe(DefaultMountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Unsubscribe(sub)
+
+	runBroadcaster(t, b)
+
+	select {
+	case chunk := <-sub.ch:
+		sub.consumed(chunk)
+		if len(chunk.data) == 0 {
+			t.Fatal("listener received an empty chunk")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("listener received no audio")
+	}
+
+	waitFor(t, 5*time.Second, "the playlist to wrap", func() bool { return len(enc.Decoded()) >= 3 })
+	want := []string{"a.mp3", "b.mp3", "a.mp3"}
+	if got := enc.Decoded()[:3]; !slices.Equal(got, want) {
+		t.Fatalf("decoded %v, want %v", got, want)
+	}
+}
+
+func TestBroadcasterSkip(t *testing.T) {
+	b, enc := newTestBroadcaster(t, "a.mp3", "b.mp3")
+	enc.SetDefaultDuration(60)
+
+	plays, err := history.New(filepath.Join(t.TempDir(), "history.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetHistory(plays)
+
+	runBroadcaster(t, b)
+
+	waitFor(t, 2*time.Second, "the first track", func() bool { return b.CurrentTrack() == "a.mp3" })
+	time.Sleep(300 * time.Millisecond) // let some of it air
+
+	b.Skip()
+	waitFor(t, 2*time.Second, "the skip", func() bool { return b.CurrentTrack() == "b.mp3" })
+
+	if got, want := enc.Decoded(), []string{"a.mp3", "b.mp3"}; !slices.Equal(got, want) {
+		t.Fatalf("decoded %v, want %v", got, want)
+	}
+
+	logged, total := plays.List(history.Query{})
+	if total != 1 {
+		t.Fatalf("history has %d plays, want 1", total)
+	}
+	p := logged[0]
+	if p.FilePath != "a.mp3" || !p.Skipped {
+		t.Fatalf("logged %q skipped=%v, want a.mp3 skipped", p.FilePath, p.Skipped)
+	}
+	if p.Duration <= 0 || p.Duration >= 5 {
+		t.Fatalf("logged %.2fs played, want the short time before the skip", p.Duration)
+	}
+}
+
+func TestBurstLongerThanBacklogLimit(t *testing.T) {
+	b := NewBroadcaster(nil, ffmpeg.NewFakeEncoder("128k", 44100, 2))
+	b.SetBurst(4 * time.Second)
+	b.SetSlowClientPolicy(SlowClientPolicy{MaxBacklog: time.Second})
+
+	b.mu.RLock()
+	m := b.mountUnsafe(DefaultMountPath)
+	b.mu.RUnlock()
+	w := &broadcastWriter{broadcaster: b, mount: m, frames: newFrameSplitter(ffmpeg.CodecMP3)}
+
+	// Fill the burst buffer with about four seconds of frames.
+	for i := 0; i < 160; i++ {
+		if _, err := w.Write(mp3Frame(false, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub, err := b.Subscribe(DefaultMountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Unsubscribe(sub)
+
+	if _, err := w.Write(mp3Frame(false, 0)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.Kicked():
+		t.Fatal("new listener was kicked for its burst")
+	default:
+	}
+
+	// Reading the burst must not leave credit against the limit either.
+	burst := <-sub.ch
+	if !burst.burst || burst.duration < 3*time.Second {
+		t.Fatalf("first chunk is not the burst (%s)", burst.duration)
+	}
+	sub.consumed(burst)
+	if got := time.Duration(sub.backlog.Load()); got <= 0 {
+		t.Fatalf("backlog = %s after reading the burst, want the live frame", got)
+	}
+}